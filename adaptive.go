@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// EscalationPolicy configures WithEscalation
+type EscalationPolicy struct {
+	Restart  int // consecutive failed checkpoints before escalating to Restart, 3 if zero
+	Truncate int // further consecutive failed checkpoints before escalating to Truncate, 3 if zero
+	Pages    int // a checkpoint fails when it leaves more than Pages pages of the WAL not written back
+}
+
+// WithEscalation escalates the mode of the automatic checkpoints while they fail to write back the WAL,
+// because they are busy or because readers pin its pages, according to the policy p
+// The checkpoints use the Passive mode unless WithMode is given after WithEscalation, then after p.Restart
+// consecutive failures at least Restart, and after p.Truncate more Truncate
+// A checkpoint that writes back the WAL restores the mode
+// The mode of each checkpoint is in its result, see also Stats.LastMode
+func WithEscalation(p EscalationPolicy) Option {
+	return func(c *Checkpointer) error {
+		if p.Restart < 0 || p.Truncate < 0 || p.Pages < 0 {
+			return fmt.Errorf("sqlite: invalid escalation policy %+v", p)
+		}
+		if p.Restart == 0 {
+			p.Restart = 3
+		}
+		if p.Truncate == 0 {
+			p.Truncate = 3
+		}
+		if c.mode == 0 {
+			c.mode = Passive
+		}
+		c.policy = p
+		c.adaptive = true
+		return nil
+	}
+}
+
+// WithAdaptiveMode escalates the mode of the automatic checkpoints at each one leaving more than pages pages
+// of the WAL not written back, typically because of readers: the next one uses at least Restart, then Truncate,
+// and the limit is halved at each step so that it comes sooner
+// A checkpoint that writes back the WAL restores the mode and the limit, see also WithEscalation
+func WithAdaptiveMode(pages int) Option {
+	return func(c *Checkpointer) error {
+		if pages < 0 {
+			return fmt.Errorf("sqlite: invalid number of pages %d", pages)
+		}
+		c.policy = EscalationPolicy{Restart: 1, Truncate: 1, Pages: pages}
+		c.adaptive = true
+		c.sooner = true
+		return nil
+	}
+}
+
+// WithMaxWALPagesBeforeForce bounds the time the writers wait for the checkpoints on a large WAL:
+// the automatic checkpoints use the Passive mode, which never blocks them, and only the one following
+// a checkpoint that left more than pages pages in the WAL uses the Truncate mode, see Stats.HardCapCheckpoints
+// Set a low limit so that the passive checkpoints are frequent and keep the WAL small
+func WithMaxWALPagesBeforeForce(pages int) Option {
+	return func(c *Checkpointer) error {
+		if pages <= 0 {
+			return fmt.Errorf("sqlite: invalid number of pages %d", pages)
+		}
+		c.mode = Passive
+		c.hardCap = pages
+		return nil
+	}
+}
+
+// overCap reports whether the last checkpoint left more pages than WithMaxWALPagesBeforeForce allows
+func (c *Checkpointer) overCap() bool {
+	return c.hardCap > 0 && atomic.LoadInt64(&c.lastPages) > int64(c.hardCap)
+}
+
+// adapt escalates or restores the mode after a checkpoint, the caller must hold the lock
+func (c *Checkpointer) adapt(res CheckpointResult, err error) {
+	if !c.adaptive || (err != nil && !isBusy(err)) {
+		return
+	}
+	left := res.WALPages - max0(res.CheckpointedPages)
+	if !res.Busy && !res.Partial() {
+		left = 0
+	}
+	if left > c.policy.Pages {
+		c.failures++
+	} else {
+		c.failures = 0
+	}
+	var level int32
+	if c.failures >= c.policy.Restart+c.policy.Truncate {
+		level = 2
+	} else if c.failures >= c.policy.Restart {
+		level = 1
+	}
+	atomic.StoreInt32(&c.escalation, level)
+}
+
+// escalated returns the mode of the automatic checkpoints escalated by WithEscalation or WithPartialPolicy
+func (c *Checkpointer) escalated() CheckpointMode {
+	switch atomic.LoadInt32(&c.escalation) {
+	case 1:
+		if c.mode < Restart {
+			return Restart
+		}
+	case 2:
+		return Truncate
+	}
+	if c.partialEscalated && c.mode < Restart {
+		return Restart
+	}
+	return c.mode
+}
+
+// effectiveLimit returns the limit, halved at each escalation of WithAdaptiveMode
+func (c *Checkpointer) effectiveLimit() uint64 {
+	limit := atomic.LoadUint64(&c.limit)
+	if limit == 0 || !c.sooner {
+		return limit
+	}
+	if limit >>= uint(atomic.LoadInt32(&c.escalation)); limit == 0 {
+		return 1
+	}
+	return limit
+}
+
+// WithAdaptiveLimit tunes the limit within min and max after each checkpoint: a complete checkpoint raises it
+// by an eighth, so that the checkpoints are less frequent, and a busy checkpoint, or a partial one leaving more pages
+// of the WAL than the previous one, halves it, see Limit
+// The limit starts from WithLimit, min by default
+func WithAdaptiveLimit(min, max uint) Option {
+	return func(c *Checkpointer) error {
+		if min == 0 || max < min {
+			return fmt.Errorf("sqlite: invalid adaptive limit (min %d, max %d)", min, max)
+		}
+		c.limitMin, c.limitMax = uint64(min), uint64(max)
+		return nil
+	}
+}
+
+// tune adjusts the limit after a checkpoint, see WithAdaptiveLimit, the caller must hold the lock
+func (c *Checkpointer) tune(res CheckpointResult, err error) {
+	if c.limitMax == 0 || (err != nil && !isBusy(err)) {
+		return
+	}
+	limit := atomic.LoadUint64(&c.limit)
+	left := res.WALPages - max0(res.CheckpointedPages)
+	switch {
+	case res.Busy || (res.Partial() && left > c.tunedLeft):
+		limit /= 2
+	case !res.Partial():
+		limit += limit/8 + 1
+	}
+	c.tunedLeft = max0(left)
+	if limit < c.limitMin {
+		limit = c.limitMin
+	} else if limit > c.limitMax {
+		limit = c.limitMax
+	}
+	atomic.StoreUint64(&c.limit, limit)
+}
@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// WithInterval starts a goroutine that checkpoints when interval elapsed since the last checkpoint,
+// whatever triggered it, if Checkpoint has been called since
+// It complements the limit: whichever is reached first triggers the checkpoint
+// The goroutine is stopped by Close
+func WithInterval(interval time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if interval <= 0 {
+			return fmt.Errorf("sqlite: invalid checkpoint interval %v", interval)
+		}
+		c.interval = interval
+		return nil
+	}
+}
+
+// WithJitter randomizes each wait of WithInterval and NewBackgroundCheckpointer by up to ±fraction of it,
+// so that instances started together do not checkpoint at the same time
+// There is no jitter by default
+func WithJitter(fraction float64) Option {
+	return func(c *Checkpointer) error {
+		if !(fraction >= 0 && fraction < 1) {
+			return fmt.Errorf("sqlite: invalid jitter %v", fraction)
+		}
+		c.jitter = fraction
+		return nil
+	}
+}
+
+// jittered returns a function randomizing the durations as set by WithJitter, for a single goroutine
+func (c *Checkpointer) jittered() func(time.Duration) time.Duration {
+	if c.jitter == 0 {
+		return func(d time.Duration) time.Duration { return d }
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return func(d time.Duration) time.Duration {
+		return d + time.Duration((2*r.Float64()-1)*c.jitter*float64(d))
+	}
+}
+
+func (c *Checkpointer) background() {
+	defer c.bg.Done()
+	jitter := c.jittered()
+	t := c.clock.NewTimer(jitter(c.interval))
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C():
+			t.Reset(jitter(c.tick()))
+		}
+	}
+}
+
+// tick checkpoints if the interval elapsed since the last checkpoint and returns the duration until the next tick
+func (c *Checkpointer) tick() time.Duration {
+	c.lock()
+	if c.closed {
+		c.unlock()
+		return c.interval
+	}
+	if wait := c.interval - c.since(c.lastAt); wait > 0 {
+		c.unlock()
+		return wait
+	}
+	if (atomic.LoadUint64(&c.i) == 0 && !c.pending) || c.held() || c.debounced() {
+		c.unlock()
+		return c.interval
+	}
+	if err := c.excludeAuto(context.Background()); err != nil {
+		c.skip()
+		c.unlock()
+		c.report(CheckpointResult{}, err)
+		return c.interval
+	}
+	res, err := c.checkpoint(context.Background(), c.autoMode())
+	c.reset()
+	c.settle()
+	c.unlock()
+	c.outcome(res, err)
+	return c.interval
+}
@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// WithBackpressure blocks the calls to Checkpoint once a checkpoint could not complete with more than high pages
+// in the WAL, typically because of a long-lived reader, until a checkpoint completes or leaves at most low pages
+// Meanwhile, one of the blocked calls retries the checkpoint with an increasing delay, up to 100ms, while the other
+// ones wait for it, and each of them gives up when its context is done
+// The retries respect WithDebounce, and the calls are not blocked while the automatic checkpoints are held off,
+// by Pause or WithDiskFullBackoff for instance
+// The failures of these checkpoints are reported like those of the other automatic checkpoints, a failure other
+// than a busy checkpoint, or ErrGuardTimeout, releases the calls since waiting does not fix it
+// It is disabled by default, see Stats.ThrottledWrites
+func WithBackpressure(high, low int) Option {
+	return func(c *Checkpointer) error {
+		if low < 0 || high <= low {
+			return fmt.Errorf("sqlite: invalid backpressure marks (%d high, %d low)", high, low)
+		}
+		c.high, c.low = high, low
+		return nil
+	}
+}
+
+// pressure updates the backpressure state after a checkpoint, the caller must hold the lock
+func (c *Checkpointer) pressure(res CheckpointResult, err error) {
+	if c.high == 0 {
+		return
+	}
+	if err != nil && !isBusy(err) {
+		return
+	}
+	// The WAL restarts after a complete checkpoint, whatever its number of pages
+	left := res.WALPages
+	if !res.Busy && !res.Partial() {
+		left = 0
+	}
+	if left > c.high {
+		atomic.StoreInt32(&c.throttled, 1)
+	} else if left <= c.low {
+		atomic.StoreInt32(&c.throttled, 0)
+	}
+}
+
+// throttle blocks while the WAL is above the high mark, a single blocked call retries the checkpoint
+// while the other ones wait for it to end
+func (c *Checkpointer) throttle(ctx context.Context) error {
+	if atomic.LoadInt32(&c.throttled) == 0 {
+		return nil
+	}
+	c.lock()
+	c.stats.ThrottledWrites++
+	c.unlock()
+	for {
+		if err := c.lockContext(ctx); err != nil {
+			return err
+		}
+		// No automatic checkpoint can lower the WAL while they are held off
+		if atomic.LoadInt32(&c.throttled) == 0 || c.closed || c.held() {
+			c.unlock()
+			return nil
+		}
+		if c.recovering == nil {
+			break
+		}
+		recovering := c.recovering
+		c.unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-recovering:
+		}
+	}
+	recovering := make(chan struct{})
+	c.recovering = recovering
+	c.unlock()
+	defer func() {
+		c.lock()
+		c.recovering = nil
+		c.unlock()
+		close(recovering)
+	}()
+	return c.relieve(ctx)
+}
+
+// relieve retries the checkpoint with an increasing delay until the WAL is back under the low mark,
+// the caller is the call of throttle recovering from the backpressure
+func (c *Checkpointer) relieve(ctx context.Context) error {
+	delay := time.Millisecond
+	for {
+		if err := c.lockContext(ctx); err != nil {
+			return err
+		}
+		if atomic.LoadInt32(&c.throttled) == 0 || c.closed || c.held() {
+			c.unlock()
+			return nil
+		}
+		if c.debounced() {
+			c.unlock()
+		} else if err := c.excludeAuto(ctx); errors.Is(err, ErrGuardTimeout) {
+			// Waiting does not fix it, like the failures below
+			atomic.StoreInt32(&c.throttled, 0)
+			c.skip()
+			c.unlock()
+			c.report(CheckpointResult{}, err)
+			return nil
+		} else if err != nil {
+			c.settle()
+			c.unlock()
+			return err
+		} else {
+			res, err := c.checkpoint(ctx, c.autoMode())
+			c.reset()
+			c.settle()
+			if err != nil && !isBusy(err) {
+				atomic.StoreInt32(&c.throttled, 0)
+			}
+			c.unlock()
+			c.outcome(res, err)
+			if atomic.LoadInt32(&c.throttled) == 0 {
+				return nil
+			}
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if delay *= 2; delay > 100*time.Millisecond {
+			delay = 100 * time.Millisecond
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackpressureFailure(t *testing.T) {
+	db := openTest(t, "test.db")
+	onError, reported := errorsOf()
+	c := newTest(t, db, WithLimit(1000), WithBackpressure(10, 1), onError)
+	atomic.StoreInt32(&c.throttled, 1)
+	db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.CheckpointContext(ctx); ctx.Err() != nil {
+		t.Fatalf("the call is still throttled after a failure: %v", err)
+	}
+	if errs := reported(); len(errs) != 1 || !errors.Is(errs[0], ErrDBClosed) {
+		t.Errorf("reported %v, want ErrDBClosed", errs)
+	}
+}
+
+func TestBackpressurePaused(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(1000), WithBackpressure(10, 1))
+	atomic.StoreInt32(&c.throttled, 1)
+	c.Pause()
+	defer c.Resume()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done, err := c.CheckpointContext(ctx)
+	if err != nil {
+		t.Fatalf("the call is throttled while paused: %v", err)
+	}
+	done()
+	if n := c.Stats().TotalCheckpoints; n != 0 {
+		t.Errorf("%d checkpoints while paused", n)
+	}
+}
+
+func TestBackpressureSingleRetry(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(1000), WithMode(Passive), WithBackpressure(10, 1))
+	// The snapshot of the reader keeps the passive checkpoints partial, above the high mark
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(`select count(*) from t`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		insert(t, db)
+	}
+	atomic.StoreInt32(&c.throttled, 1)
+
+	const writers = 8
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func() {
+			_, err := c.CheckpointContext(ctx)
+			errs <- err
+		}()
+	}
+	for i := 0; i < writers; i++ {
+		if err := <-errs; err != context.DeadlineExceeded {
+			t.Errorf("throttled call returned %v", err)
+		}
+	}
+	// About log2(100)+3 retries with the delays of a single call, instead of as many per call
+	if n := c.Stats().TotalCheckpoints; n == 0 || n > 15 {
+		t.Errorf("%d checkpoints", n)
+	}
+}
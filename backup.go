@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BackupOption configures Backup
+type BackupOption func(*backupConfig)
+
+type backupConfig struct {
+	overwrite bool
+	schema    string
+}
+
+// WithOverwrite lets Backup replace an existing destination file
+func WithOverwrite() BackupOption {
+	return func(b *backupConfig) { b.overwrite = true }
+}
+
+// Backup copies the main database of db to the file dest with vacuum into, which is consistent
+// even while other connections write
+// It fails if dest exists, unless WithOverwrite is used, or if its directory does not exist,
+// it retries the copy while the database is busy or locked like Retry
+// The copy is written to a temporary file next to dest, renamed to dest once complete and removed if it fails,
+// so that an existing dest is only replaced by a complete copy
+func Backup(ctx context.Context, db *sql.DB, dest string, opts ...BackupOption) error {
+	var b backupConfig
+	for _, opt := range opts {
+		opt(&b)
+	}
+	return backup(ctx, db, dest, b)
+}
+
+func backup(ctx context.Context, db queryer, dest string, b backupConfig) error {
+	if dest == "" {
+		return errors.New("sqlite: empty backup destination")
+	}
+	if fi, err := os.Stat(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("sqlite: backup destination: %w", err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("sqlite: backup destination %s is not in a directory", dest)
+	}
+	if err := checkDest(dest, b.overwrite); err != nil {
+		return err
+	}
+	// vacuum into accepts an empty file
+	f, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("sqlite: backup: %w", err)
+	}
+	tmp := f.Name()
+	f.Close()
+	query := `vacuum into ?`
+	if b.schema != "" {
+		query = `vacuum ` + quoteIdent(b.schema) + ` into ?`
+	}
+	if err := retryBusy(ctx, func() error {
+		_, err := db.ExecContext(ctx, query, tmp)
+		if err != nil {
+			// The next attempt creates it again
+			os.Remove(tmp)
+		}
+		return err
+	}, defaultRetry); err != nil {
+		return fmt.Errorf("sqlite: backup: %w", err)
+	}
+	if err := checkDest(dest, b.overwrite); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("sqlite: backup: %w", err)
+	}
+	return nil
+}
+
+// checkDest fails if the backup destination exists, unless overwrite is true
+func checkDest(dest string, overwrite bool) error {
+	_, err := os.Stat(dest)
+	switch {
+	case err == nil && !overwrite:
+		return fmt.Errorf("sqlite: backup destination %s already exists", dest)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("sqlite: backup destination: %w", err)
+	}
+	return nil
+}
+
+// Backup is like the Backup function for the database of the Checkpointer (the first one with NewMultiCheckpointer)
+// and the schema set by WithSchema: it first performs a Truncate checkpoint, waiting for the calls to Checkpoint
+// in progress, then holds off the automatic checkpoints until the copy is done, so that they do not wait for it
+// The writers are not blocked during the copy, which is consistent anyway
+// It is the BackupTo of a single-file backup: the destination is validated, the copy is retried while the database
+// is busy and replaces dest only once complete, like with the Backup function
+func (c *Checkpointer) Backup(ctx context.Context, dest string, opts ...BackupOption) error {
+	b := backupConfig{schema: c.schema}
+	for _, opt := range opts {
+		opt(&b)
+	}
+	// The copy reads the WAL anyway, a busy checkpoint only leaves it larger
+	if _, err := c.force(ctx, Truncate); err != nil && !isBusy(err) {
+		return err
+	}
+	c.hold()
+	defer c.release()
+	return backup(ctx, c.dbs[0].q, dest, b)
+}
+
+// BackupCoordinator is implemented by *Checkpointer, for the backup subsystems copying the database files
+type BackupCoordinator interface {
+	Quiesce(ctx context.Context) (release func(), err error)
+}
+
+var _ BackupCoordinator = (*Checkpointer)(nil)
+
+// Quiesce waits for the calls to Checkpoint in progress and performs a Truncate checkpoint, then blocks the writes
+// and the checkpoints until release is called, so that the main database file is stable and up to date, for instance
+// to copy it or to run vacuum into
+// Meanwhile, the calls to Checkpoint and to the other methods of the Checkpointer wait, the writes that do not
+// call Checkpoint are not blocked
+// It returns ErrCheckpointBusy if the WAL could not be emptied, see ForceCheckpoint and WaitForEmptyWAL,
+// and ErrClosed once Close was called
+// Only the first call of release does something
+func (c *Checkpointer) Quiesce(ctx context.Context) (release func(), err error) {
+	if err := c.lockContext(ctx); err != nil {
+		return nop, err
+	}
+	if c.closed {
+		c.unlock()
+		return nop, ErrClosed
+	}
+	if err := c.exclude(ctx); err != nil {
+		c.settle()
+		c.unlock()
+		return nop, err
+	}
+	res, err := c.checkpoint(ctx, Truncate)
+	c.reset()
+	if err != nil {
+		c.settle()
+		c.unlock()
+		c.notify(res)
+		return nop, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.settle()
+			c.unlock()
+			c.notify(res)
+		})
+	}, nil
+}
@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// count returns the number of rows of t in the database file at path
+func count(t *testing.T, path string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`select count(*) from t`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	db := openTest(t, "test.db")
+	for i := 0; i < 100; i++ {
+		insert(t, db)
+	}
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "backup.db")
+	if err := Backup(ctx, db, dest); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, dest); n != 100 {
+		t.Errorf("%d rows in the backup, want 100", n)
+	}
+	insert(t, db)
+	if err := Backup(ctx, db, dest); err == nil {
+		t.Error("existing destination overwritten without WithOverwrite")
+	}
+	if err := Backup(ctx, db, dest, WithOverwrite()); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, dest); n != 101 {
+		t.Errorf("%d rows in the overwritten backup, want 101", n)
+	}
+	// A failed backup keeps the previous one and leaves no temporary file
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := Backup(canceled, db, dest, WithOverwrite()); err == nil {
+		t.Error("no error with a canceled context")
+	}
+	if n := count(t, dest); n != 101 {
+		t.Errorf("%d rows in the backup after a failure, want 101", n)
+	}
+	if entries, err := os.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 {
+		t.Errorf("%d files in the directory of the backup, want 1", len(entries))
+	}
+}
+
+func TestCheckpointerBackup(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(10))
+	for i := 0; i < 25; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if err := c.Backup(context.Background(), dest); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, dest); n != 25 {
+		t.Errorf("%d rows in the backup, want 25", n)
+	}
+}
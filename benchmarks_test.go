@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// BenchmarkInsert measures the throughput of inserts counted by a Checkpointer and the size of the WAL they leave,
+// for the limits, modes, numbers of concurrent writers and row sizes, to choose the limit of a workload:
+//
+//	go test -run - -bench Insert -benchtime 10000x
+//
+// walbytes is the size of the WAL files after the inserts, maxwalbytes their largest size sampled every 100 inserts
+func BenchmarkInsert(b *testing.B) {
+	for _, limit := range []uint{100, 1000, 10000} {
+		for _, mode := range []CheckpointMode{Passive, Restart, Truncate} {
+			for _, writers := range []int{1, 8} {
+				for _, rowSize := range []int{100, 4000} {
+					name := fmt.Sprintf("limit=%d/mode=%v/writers=%d/row=%d", limit, mode, writers, rowSize)
+					b.Run(name, func(b *testing.B) {
+						benchmarkInsert(b, limit, mode, writers, rowSize)
+					})
+				}
+			}
+		}
+	}
+}
+
+func benchmarkInsert(b *testing.B, limit uint, mode CheckpointMode, writers, rowSize int) {
+	db := openTest(b, "test.db")
+	c := newTest(b, db, WithLimit(limit), WithMode(mode))
+	var maxWAL int64
+	var mu sync.Mutex
+	sample := func() {
+		_, wal, _, err := c.Sizes(context.Background())
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		mu.Lock()
+		if wal > maxWAL {
+			maxWAL = wal
+		}
+		mu.Unlock()
+	}
+	b.SetBytes(int64(rowSize))
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	work := make(chan int, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				done := c.Checkpoint()
+				_, err := db.Exec(`insert into t values (randomblob(?))`, rowSize)
+				done()
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				if i%100 == 0 {
+					sample()
+				}
+			}
+		}()
+	}
+	for i := 0; i < b.N; i++ {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	b.StopTimer()
+	_, wal, _, err := c.Sizes(context.Background())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(wal), "walbytes")
+	b.ReportMetric(float64(maxWAL), "maxwalbytes")
+}
+
+// BenchmarkBatch compares loading rows with Batch, in transactions of chunk rows, to one Exec per row,
+// both checkpointing every 1000 rows:
+//
+//	go test -run - -bench Batch
+func BenchmarkBatch(b *testing.B) {
+	b.Run("Exec", func(b *testing.B) {
+		db := openTest(b, "test.db")
+		c := newTest(b, db, WithLimit(1000))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.Exec(`insert into t values (?)`, i); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, chunk := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("chunk=%d", chunk), func(b *testing.B) {
+			db := openTest(b, "test.db")
+			c := newTest(b, db, WithLimit(uint(1000/chunk)))
+			b.ResetTimer()
+			if err := c.Batch(context.Background(), chunk, func(tx *sql.Tx, i int) (bool, error) {
+				if i == b.N {
+					return true, nil
+				}
+				_, err := tx.Exec(`insert into t values (?)`, i)
+				return false, err
+			}); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
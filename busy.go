@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// WithBusyTimeout sets pragma busy_timeout so that the checkpoints wait for the locks held by other
+// connections instead of failing or reporting busy immediately
+// busy_timeout is a setting of each connection: it is set on a connection of the pool during NewCheckpointer
+// and on the connection performing each checkpoint, the other connections of the pool keep their own
+// value, that can be set in the DSN
+func WithBusyTimeout(d time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if d < 0 {
+			return fmt.Errorf("sqlite: invalid busy timeout %v", d)
+		}
+		c.busyTimeout = d
+		return nil
+	}
+}
+
+func (c *Checkpointer) busyTimeoutPragma() string {
+	return `pragma busy_timeout = ` + strconv.FormatInt(c.busyTimeout.Milliseconds(), 10)
+}
+
+// queryer is implemented by *sql.DB and *sql.Conn
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// handle is a database checkpointed by a Checkpointer, *sql.DB or *sql.Conn, see NewCheckpointerConn
+type handle interface {
+	queryer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// single returns a single connection of d, the one of NewCheckpointerConn or one taken from the pool,
+// and the function releasing it
+func (d *database) single(ctx context.Context) (queryer, func() error, error) {
+	if d.db == nil {
+		return d.q, func() error { return nil }, nil
+	}
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn.Close, nil
+}
+
+// WithDedicatedConn performs the checkpoints on a connection taken from the pool of each database
+// by NewCheckpointer and kept until Close, so that they do not wait for a connection of the pool
+// The pool then has one connection less for the other operations
+func WithDedicatedConn() Option {
+	return func(c *Checkpointer) error {
+		c.dedicated = true
+		return nil
+	}
+}
+
+// setup sets the pragmas of WithBusyTimeout, WithSynchronous and WithJournalSizeLimit on a connection
+func (c *Checkpointer) setup(ctx context.Context, q queryer) error {
+	if c.busyTimeout > 0 {
+		if _, err := q.ExecContext(ctx, c.busyTimeoutPragma()); err != nil {
+			return err
+		}
+	}
+	if c.synchronous != "" {
+		if _, err := q.ExecContext(ctx, `pragma synchronous = `+c.synchronous); err != nil {
+			return err
+		}
+	}
+	if c.journalSizeLimit >= 0 {
+		if _, err := q.ExecContext(ctx, c.journalSizeLimitPragma()+` = `+strconv.FormatInt(c.journalSizeLimit, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointDB checkpoints schema of a database (all the attached ones if empty), on its dedicated connection if any,
+// otherwise on a connection with the pragmas of WithBusyTimeout, WithSynchronous and WithJournalSizeLimit if they are set
+func (c *Checkpointer) checkpointDB(ctx context.Context, d *database, schema string, mode CheckpointMode) (CheckpointResult, error) {
+	if c.dryRun {
+		return CheckpointResult{WALPages: -1, CheckpointedPages: -1, Mode: mode}, nil
+	}
+	if d.conn != nil {
+		return c.checkpointConn(ctx, d.conn, schema, mode)
+	}
+	if c.busyTimeout == 0 && c.synchronous == "" && c.journalSizeLimit < 0 && !c.txCheck {
+		return checkpoint(ctx, d.db, schema, mode)
+	}
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return CheckpointResult{Mode: mode}, checkpointError(err)
+	}
+	defer conn.Close()
+	if err := c.setup(ctx, conn); err != nil {
+		return CheckpointResult{Mode: mode}, checkpointError(err)
+	}
+	return c.checkpointConn(ctx, conn, schema, mode)
+}
+
+// checkpointConn checkpoints on a connection, checking it for an open transaction if it fails with WithTxCheck
+func (c *Checkpointer) checkpointConn(ctx context.Context, q queryer, schema string, mode CheckpointMode) (CheckpointResult, error) {
+	res, err := checkpoint(ctx, q, schema, mode)
+	if err != nil && c.txCheck && !errors.Is(err, ErrDBClosed) {
+		err = checkTx(ctx, q, err)
+	}
+	return res, err
+}
+
+// closeConns closes the connections of WithDedicatedConn and WithHoldOpen, not the one of NewCheckpointerConn,
+// and the lock file of WithProcessLock
+func (c *Checkpointer) closeConns() error {
+	var err error
+	for _, d := range c.dbs {
+		if d.db == nil {
+			continue
+		}
+		for _, conn := range []*sql.Conn{d.conn, d.hold} {
+			if conn != nil {
+				if err2 := conn.Close(); err == nil {
+					err = err2
+				}
+			}
+		}
+	}
+	if c.lockFile != nil {
+		if err2 := c.lockFile.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WithRowChangeLimit triggers a checkpoint once rows rows were inserted, updated or deleted since the last
+// successful checkpoint, according to total_changes(), so that the checkpoints follow the writes rather than the calls
+// total_changes() counts the changes of a connection, so it requires NewCheckpointerConn with the connection of the writes,
+// NewCheckpointer fails otherwise
+// It is sampled every 64 calls to Checkpoint, not on every call, see Stats.RowsChanged
+// It complements the limit: whichever is reached first triggers the checkpoint
+func WithRowChangeLimit(rows int64) Option {
+	return func(c *Checkpointer) error {
+		if rows <= 0 {
+			return fmt.Errorf("sqlite: invalid row change limit %d", rows)
+		}
+		c.rowLimit = rows
+		return nil
+	}
+}
+
+// errRowChangePool is returned by NewCheckpointer with WithRowChangeLimit
+var errRowChangePool = errors.New("sqlite: WithRowChangeLimit requires NewCheckpointerConn, total_changes() is counted per connection")
+
+// totalChanges returns total_changes() of the connection q
+func totalChanges(ctx context.Context, q queryer) (int64, error) {
+	var n int64
+	err := q.QueryRowContext(ctx, `select total_changes()`).Scan(&n)
+	return n, err
+}
+
+// rowsOver samples the changes since the last checkpoint and reports whether they reach the limit
+// of WithRowChangeLimit, the caller must hold the lock
+func (c *Checkpointer) rowsOver() (bool, error) {
+	var changed int64
+	for _, d := range c.dbs {
+		n, err := totalChanges(context.Background(), d.q)
+		if err != nil {
+			return false, fmt.Errorf("counting changes: %w", err)
+		}
+		changed += n - d.changes
+	}
+	c.stats.RowsChanged = changed
+	return changed >= c.rowLimit, nil
+}
+
+// rebaseChanges counts the changes from now on, after a successful checkpoint, the caller must hold the lock
+func (c *Checkpointer) rebaseChanges(ctx context.Context) {
+	for _, d := range c.dbs {
+		// On failure, the next checkpoint may come sooner
+		if n, err := totalChanges(ctx, d.q); err == nil {
+			d.changes = n
+		}
+	}
+	c.stats.RowsChanged = 0
+}
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// CheckOption configures IntegrityCheck
+type CheckOption func(*checkConfig) error
+
+type checkConfig struct {
+	quick     bool
+	maxErrors int
+}
+
+// WithQuickCheck runs pragma quick_check instead of pragma integrity_check,
+// which is much faster but does not check that the indexes match the tables
+func WithQuickCheck() CheckOption {
+	return func(c *checkConfig) error {
+		c.quick = true
+		return nil
+	}
+}
+
+// WithMaxErrors stops the check after n problems, the default of SQLite is 100
+func WithMaxErrors(n int) CheckOption {
+	return func(c *checkConfig) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid maximum number of errors %d", n)
+		}
+		c.maxErrors = n
+		return nil
+	}
+}
+
+// IntegrityCheck runs pragma integrity_check on db and returns the problems it found, none if the database is sound
+// The check of a large database can take minutes, it is interrupted when ctx is done
+func IntegrityCheck(ctx context.Context, db *sql.DB, opts ...CheckOption) ([]string, error) {
+	var c checkConfig
+	for _, opt := range opts {
+		if err := opt(&c); err != nil {
+			return nil, err
+		}
+	}
+	query := `pragma integrity_check`
+	if c.quick {
+		query = `pragma quick_check`
+	}
+	if c.maxErrors > 0 {
+		query += `(` + strconv.Itoa(c.maxErrors) + `)`
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		problems = append(problems, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(problems) == 1 && problems[0] == "ok" {
+		return []string{}, nil
+	}
+	return problems, nil
+}
+
+// ForeignKeyViolation is a row of pragma foreign_key_check
+type ForeignKeyViolation struct {
+	Table  string        // table containing the row
+	RowID  sql.NullInt64 // rowid of the row, null for WITHOUT ROWID tables
+	Parent string        // table referenced by the foreign key
+	FKID   int           // index of the foreign key in pragma foreign_key_list(Table)
+}
+
+// ForeignKeyCheck runs pragma foreign_key_check on db and returns the rows violating foreign key constraints
+func ForeignKeyCheck(ctx context.Context, db *sql.DB) ([]ForeignKeyViolation, error) {
+	rows, err := db.QueryContext(ctx, `pragma foreign_key_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var violations []ForeignKeyViolation
+	for rows.Next() {
+		var v ForeignKeyViolation
+		if err := rows.Scan(&v.Table, &v.RowID, &v.Parent, &v.FKID); err != nil {
+			return nil, err
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
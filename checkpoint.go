@@ -1,58 +1,1200 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Checkpointer is an opaque structure, see NewCheckPointer
+// ErrCheckpointBusy is reported when the checkpoint could not complete because of
+// concurrent readers or writers (the pragma returned busy = 1)
+var ErrCheckpointBusy = errors.New("sqlite: checkpoint busy")
+
+// ErrCheckpointPartial is matched by the CheckpointErrors of the checkpoints that did not write back every page
+// of the WAL, along with ErrCheckpointBusy: a reader or a writer is in the way, unlike the other failures
+// The successful Passive checkpoints can be partial too, see CheckpointResult.Partial
+var ErrCheckpointPartial = errors.New("sqlite: checkpoint partial")
+
+// ErrClosed is returned by CheckpointContext once the Checkpointer is closed
+var ErrClosed = errors.New("sqlite: checkpointer closed")
+
+// ErrNilDB is returned by the constructors when given a nil database
+var ErrNilDB = errors.New("sqlite: nil database")
+
+// ErrAutocheckpoint is returned by NewCheckpointer when the connections of the pool may checkpoint automatically:
+// wal_autocheckpoint is a setting of each connection and database/sql has no hook for the new ones,
+// so it must be disabled by the connector of the pool, see OpenWithCheckpointer and NewConnector
+var ErrAutocheckpoint = errors.New("sqlite: wal_autocheckpoint is not disabled on every connection of the pool, open it with OpenWithCheckpointer or NewConnector")
+
+// ErrDBClosed is reported when a checkpoint fails because the database or its connection is closed,
+// the Checkpointer then behaves as if it was closed
+var ErrDBClosed = errors.New("sqlite: database closed")
+
+// CheckpointError is the error of a failed checkpoint, as reported to the handler of WithErrorHandler
+// The busy and partial checkpoints are told apart from the other failures by the result of the pragma,
+// not by the error codes of the driver, so that errors.Is(err, ErrCheckpointBusy) is the same with every driver
+// Otherwise, Err wraps the error of the driver
+type CheckpointError struct {
+	Database          int            // index of the database in NewMultiCheckpointer, 0 otherwise
+	Mode              CheckpointMode // mode of the checkpoint
+	Busy              bool           // the pragma returned busy = 1, Err is ErrCheckpointBusy, otherwise the pragma failed
+	WALPages          int            // number of pages in the WAL returned by the pragma, 0 if it failed
+	CheckpointedPages int            // number of pages written back returned by the pragma, 0 if it failed
+	Err               error
+	multi             bool
+}
+
+func newCheckpointError(i int, multi bool, res CheckpointResult, err error) *CheckpointError {
+	return &CheckpointError{
+		Database:          i,
+		Mode:              res.Mode,
+		Busy:              errors.Is(err, ErrCheckpointBusy),
+		WALPages:          res.WALPages,
+		CheckpointedPages: res.CheckpointedPages,
+		Err:               err,
+		multi:             multi,
+	}
+}
+
+func (e *CheckpointError) Error() string {
+	if e.multi {
+		return "database " + strconv.Itoa(e.Database) + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *CheckpointError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the checkpoint was partial for ErrCheckpointPartial
+func (e *CheckpointError) Is(target error) bool {
+	return target == ErrCheckpointPartial && e.CheckpointedPages >= 0 && e.CheckpointedPages < e.WALPages
+}
+
+// Checkpointer is an opaque structure, see NewCheckpointer
 type Checkpointer struct {
-	m     sync.Mutex
-	wg    sync.WaitGroup
-	db    *sql.DB
-	limit uint
-	i     uint
+	// Accessed atomically, the 64-bit ones first for their alignment
+	i              uint64 // number of calls since the last checkpoint
+	limit          uint64 // weight of the calls that triggers a checkpoint, 0 to disable, see SetLimit
+	lastEnd        int64  // end of the last checkpoint in Unix nanoseconds, see WithTrigger and SinceLastCheckpoint
+	lastPages      int64  // WAL pages left by the last checkpoint, see WithTrigger
+	lastCall       int64  // last call to Checkpoint in Unix nanoseconds, see WithIdleCheckpoint
+	doubleReleases uint64 // see Stats.DoubleReleases
+	leaks          uint64 // see Stats.LeakedGuards
+	attributed     uint64 // calls of CheckpointFor since the last checkpoint
+	postOps        uint64 // calls to Checkpoint ended, see WithPostOpSizeCheck
+	drained        int32  // see DrainAndCheckpoint
+	throttled      int32  // the WAL is above the high mark of WithBackpressure
+	running        int32  // number of checkpoints running, see Inspect
+	stuck          int32  // the health became Stuck and ErrStuck is not reported yet
+	pinned         int32  // the WAL became pinned and ErrWALPinned is not reported yet
+	escalation     int32  // escalation level of WithEscalation
+	snapshots      int32  // number of calls to Snapshot in progress
+	holds          int32  // number of operations holding off the automatic checkpoints, see Pause
+	armed          int32  // a check of WithPostOpSizeCheck found a WAL over the limit
+
+	config
+
+	g    gate           // synchronized by itself, counts the calls in progress
+	m    chan struct{}  // mutex that can be acquired with a context
+	subs subscribers    // see Subscribe, synchronized by itself
+	bg   sync.WaitGroup // background goroutines
+
+	// Set by the options or NewCheckpointer, then synchronized by themselves
+	mode     CheckpointMode // mode of the checkpoints, set once the journal mode is known, see autoMode for the automatic ones
+	stop     chan struct{}  // closed by Close to stop the background goroutines
+	lockFile *os.File       // lock file of WithProcessLock, locked around each checkpoint and closed by Close
+	guards   *guards        // see WithGuardStacks
+	queue    *writeQueue    // set by WithWriteQueue
+
+	// Guarded by m
+	pauses                      int // number of calls to Pause not followed by Resume
+	last                        CheckpointResult
+	lastErr                     error
+	stats                       Stats
+	expvar                      *expvar.Map       // see PublishExpvar
+	pending                     bool              // a checkpoint was due but could not be performed, the gate stays blocked
+	lastAt                      time.Time         // start of the last checkpoint
+	doneAt                      time.Time         // end of the last checkpoint
+	reclaims                    []float64         // window of WithMinReclaimPct
+	reclaimNext                 int               // oldest entry of reclaims once it is full
+	reclaimLow                  bool              // the average of reclaims is below minReclaim
+	lowReclaim                  error             // ErrLowReclaim to report after the checkpoint
+	partialEscalated            bool              // the automatic checkpoints use at least Restart, see WithPartialPolicy
+	partialStreak               int               // partial checkpoints in a row to report after the checkpoint
+	incomplete                  int               // number of busy or partial checkpoints in a row, see Health
+	failedInRow                 int               // number of failed checkpoints in a row, not counting the busy ones, see IsHealthy
+	completeAt                  time.Time         // start of the last checkpoint that wrote back the whole WAL
+	prevPages, prevCheckpointed int               // pages of the previous checkpoint, see ErrWALPinned
+	closed                      bool              // Close was called or a checkpoint failed with ErrDBClosed
+	terminated                  bool              // closed because a checkpoint failed with ErrDBClosed, Close was not called yet
+	retrying                    bool              // the retry goroutine of WithBusyRetry is running
+	selective                   bool              // the next checkpoint is automatic, see CheckpointFor
+	failures                    int               // consecutive checkpoints failing the policy
+	untilTruncate               uint              // automatic checkpoints until the next Truncate one of WithTruncateEvery
+	truncating                  bool              // the next checkpoint is the Truncate one of WithTruncateEvery
+	history                     []CheckpointEvent // see WithHistory
+	historyNext                 int               // index of the next event in history
+	historyLen                  int               // number of events in history
+	tunedLeft                   int               // pages left in the WAL by the last checkpoint, see WithAdaptiveLimit
+	backoffDelay                time.Duration     // current delay of WithDiskFullBackoff, 0 after a success
+	backoffTimer                *time.Timer       // ends the delay of WithDiskFullBackoff, nil if the checkpoints are not held off
+	slowGuards                  error             // ErrSlowGuard recorded by exclude for the next checkpoint
+	ingest                      *ingest           // see IngestMode
+	delayed                     timer             // see ScheduleCheckpoint
+	idleTimer                   timer             // timer of WithIdleCheckpoint, created by NewCheckpointer
+	hist                        histogram         // durations of the checkpoints, see WritePrometheus
+	written                     uint64            // weight of the calls before the last reset of the counter
+	analyzed                    uint64            // value of written plus the counter at the last analyze
+	successes                   int               // number of successful checkpoints, see maintain
+	waited                      time.Duration     // duration of the last exclude, see CheckpointResult.Wait
+	scheduled                   bool              // a concurrent passive checkpoint is running
+	recovering                  chan struct{}     // closed when the call retrying the checkpoint of WithBackpressure ends
+}
+
+// config is the configuration of a Checkpointer, set by the options and NewCheckpointer and immutable afterwards,
+// so that it is read without the lock, see run
+// The databases of dbs are not, some of their fields are accessed atomically or under the lock, see database
+type config struct {
+	dbs              []*database
+	onError          func(error)
+	onCheckpoint     func(CheckpointResult)
+	beforeCheckpoint func()
+	afterCheckpoint  func(CheckpointResult, error)
+	onDurable        func(time.Time)
+	onDiskFull       func(error) // see WithOnDiskFull
+	logger           Logger
+	errCh            chan<- error
+	trace            Hooks // see WithTraceHooks
+
+	walLimit     int64         // size of the WAL file that triggers a checkpoint, 0 to disable
+	pageLimit    int           // number of pages of the WAL that triggers a checkpoint, 0 to disable
+	postOpEvery  uint64        // see WithPostOpSizeCheck
+	rowLimit     int64         // see WithRowChangeLimit
+	interval     time.Duration // maximum duration between two checkpoints, 0 to disable
+	jitter       float64       // see WithJitter
+	debounce     time.Duration // see WithDebounce
+	idle         time.Duration // see WithIdleCheckpoint
+	pollPages    int           // WAL pages threshold of NewBackgroundCheckpointer
+	pollInterval time.Duration
+	trigger      func(TriggerState) bool // see WithTrigger
+	cron         *cron                   // see WithSchedule
+
+	stuckAfter    int              // see WithStuckAfter
+	minReclaim    float64          // see WithMinReclaimPct
+	partialPolicy PartialPolicy    // see WithPartialPolicy
+	thresholds    HealthThresholds // see WithHealthThresholds
+	retryDelays   []time.Duration  // delays before each retry of a busy checkpoint
+	adaptive      bool             // see WithEscalation and WithAdaptiveMode
+	policy        EscalationPolicy
+	sooner        bool   // see WithAdaptiveMode
+	hardCap       int    // see WithMaxWALPagesBeforeForce
+	truncateEvery uint   // see WithTruncateEvery
+	limitMin      uint64 // see WithAdaptiveLimit
+	limitMax      uint64
+	backoffMin    time.Duration // see WithDiskFullBackoff
+	backoffMax    time.Duration
+	guardTimeout  time.Duration // see WithGuardTimeout
+	maxGuard      time.Duration // see WithMaxGuardDuration
+	ceiling       int           // see WithOpportunistic
+	high, low     int           // see WithBackpressure
+	concurrent    bool          // see WithConcurrentPassive
+
+	readDB          *sql.DB       // see WithReadDB
+	serial          chan struct{} // shared by the checkpointers of a Manager so that one checkpoint runs at a time
+	processLock     bool          // see WithProcessLock
+	processLockPath string        // see WithProcessLock, empty for the default
+
+	enableWAL        bool
+	enableWAL2       bool
+	wal2             bool // every database is in WAL2 mode
+	managedPragmas   bool // see WithManagedPragmas
+	dryRun           bool // see WithDryRun
+	leakDetection    bool // see WithLeakDetection
+	busyTimeout      time.Duration
+	synchronous      string // see WithSynchronous
+	journalSizeLimit int64  // see WithJournalSizeLimit, -1 if unset
+	dedicated        bool   // see WithDedicatedConn
+	holdOpen         bool   // see WithHoldOpen
+	txCheck          bool   // see WithTxCheck
+	schema           string // schema given to pragma wal_checkpoint, all the attached databases if empty
+
+	optimizeEvery int    // see WithOptimize
+	vacuumEvery   int    // see WithIncrementalVacuum
+	vacuumPages   int    // see WithIncrementalVacuum
+	analyzeEvery  uint64 // see WithAnalyze
+	analysisLimit int    // see WithAnalysisLimit
+
+	clock   clock   // see withClock
+	version Version // see Version
+	name    string  // see WithName
+}
+
+// database is a database checkpointed by a Checkpointer
+type database struct {
+	writes         uint64    // calls of CheckpointFor since the last checkpoint, accessed atomically, first for 64-bit alignment
+	q              handle    // db, or the connection of NewCheckpointerConn
+	db             *sql.DB   // nil with NewCheckpointerConn
+	walPath        string    // path of the WAL file, only set with WithWALSizeLimit
+	autocheckpoint int       // value of wal_autocheckpoint before NewCheckpointer, restored by Close
+	conn           *sql.Conn // connection of the checkpoints, only set with WithDedicatedConn and NewCheckpointerConn
+	hold           *sql.Conn // connection keeping the database open, only set with WithHoldOpen, guarded by the lock
+	managed        bool      // wal_autocheckpoint is set by the connector of the database, see NewConnector
+	changes        int64     // total_changes() after the last successful checkpoint, only set with WithRowChangeLimit, guarded by the lock
+}
+
+// Option configures a Checkpointer, see NewCheckpointer
+type Option func(*Checkpointer) error
+
+// WithLimit triggers a checkpoint every limit calls to Checkpoint: the call following limit calls
+// performs the checkpoint once they are done, before its own operation, and is the first of the next limit calls
+// For instance with a limit of 1000, the calls 1001, 2001, 3001... perform the checkpoints
+// With CheckpointN, the weights of the calls are counted instead
+// A zero limit disables this trigger, leaving the other ones (the WAL size, the interval, or manual checkpoints)
+func WithLimit(limit uint) Option {
+	return func(c *Checkpointer) error {
+		c.limit = uint64(limit)
+		return nil
+	}
 }
 
-// NewCheckPointer returns an SQLite WAL checkpointer, it is a workaround before WAL2 becomes common:
+// SetLimit changes the limit set by WithLimit, a zero limit disables this trigger
+// If the calls since the last checkpoint already reach the new limit, the next call performs the checkpoint
+func (c *Checkpointer) SetLimit(limit uint) {
+	c.lock()
+	defer c.unlock()
+	atomic.StoreUint64(&c.limit, uint64(limit))
+	if due, _ := c.due(0); due && !c.closed {
+		c.pending = true
+		c.g.block()
+	}
+}
+
+// Limit returns the limit set by WithLimit or SetLimit, as tuned by WithAdaptiveLimit
+func (c *Checkpointer) Limit() uint {
+	return uint(atomic.LoadUint64(&c.limit))
+}
+
+// WithErrorHandler sets a function called with every failure of a checkpoint triggered by Checkpoint,
+// or by the goroutines of WithInterval and NewBackgroundCheckpointer
+// The failure of a checkpoint is a *CheckpointError, the handler is called once per failed database with
+// NewMultiCheckpointer, other errors (of the WAL size check or of WithGuardTimeout for instance) are passed as is
+// It is called by the goroutine that performed the checkpoint, after writers are unblocked
+func WithErrorHandler(f func(error)) Option {
+	return func(c *Checkpointer) error {
+		c.onError = f
+		return nil
+	}
+}
+
+// NewCheckPointer returns a checkpointer with the given limit, see NewCheckpointer and WithLimit
+// A zero limit is an error unless another trigger is set by opts
+//
+// Deprecated: use NewCheckpointer(db, WithLimit(limit), opts...)
+func NewCheckPointer(db *sql.DB, limit uint, opts ...Option) (*Checkpointer, error) {
+	return NewCheckpointer(db, append([]Option{WithLimit(limit)}, opts...)...)
+}
+
+// NewCheckpointer returns an SQLite WAL checkpointer, it is a workaround before WAL2 becomes common:
 // https://www.sqlite.org/cgi/src/doc/wal2/doc/wal2.md
 // Currently, concurrent writes in SQLite make the WAL file grow without limit
-func NewCheckPointer(db *sql.DB, limit uint) (*Checkpointer, error) {
-	if _, err := db.Exec(`pragma wal_autocheckpoint = 0`); err != nil {
-		return nil, err
+// At least one trigger must be set with WithLimit, WithWALSizeLimit, WithWALPageLimit, WithRowChangeLimit, WithInterval, WithIdleCheckpoint, WithSchedule or WithTrigger
+// The database must be in WAL journal mode, see WithEnableWAL
+// The checkpoints use the Restart mode unless WithMode is given
+// wal_autocheckpoint is a setting of each connection and database/sql has no hook for the new ones:
+// NewCheckpointer returns ErrAutocheckpoint unless the connector of db disables it on every connection, as with
+// OpenWithCheckpointer, Open and WithPragma("wal_autocheckpoint", "0"), or NewConnector
+// In WAL2 mode, wal_autocheckpoint is left as is, see WithWAL2
+func NewCheckpointer(db *sql.DB, opts ...Option) (*Checkpointer, error) {
+	return newCheckpointer([]handle{db}, opts)
+}
+
+// NewCheckpointerConn is like NewCheckpointer for a single connection, for instance when it is the only one
+// used by the writes: the pragmas and the checkpoints are performed on conn, so that they never wait
+// for the other connections of the pool
+// The caller owns conn: Close does not close it, it must be closed after the Checkpointer
+// A transaction of conn not wrapped by Checkpoint makes the checkpoints fail, see WithTxCheck
+// WithDedicatedConn and WithHoldOpen have no effect, and DB.Unwrap returns nil
+func NewCheckpointerConn(conn *sql.Conn, opts ...Option) (*Checkpointer, error) {
+	return newCheckpointer([]handle{conn}, opts)
+}
+
+func newCheckpointer(dbs []handle, opts []Option) (*Checkpointer, error) {
+	c := &Checkpointer{
+		g: newGate(),
+		m: make(chan struct{}, 1),
+
+		lastPages: -1,
+
+		stop: make(chan struct{}),
+
+		config: config{
+			clock: realClock{},
+
+			stuckAfter:       defaultStuckAfter,
+			thresholds:       defaultHealthThresholds,
+			journalSizeLimit: -1,
+
+			guardTimeout: defaultGuardTimeout,
+		},
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	c.lastAt = c.clock.Now()
+	c.lastEnd, c.completeAt = c.lastAt.UnixNano(), c.lastAt
+	if c.limitMax > 0 && c.limit < c.limitMin {
+		c.limit = c.limitMin
+	} else if c.limitMax > 0 && c.limit > c.limitMax {
+		c.limit = c.limitMax
+	}
+	if c.postOpEvery > 0 && c.walLimit == 0 && c.pageLimit == 0 {
+		return nil, errors.New("sqlite: WithPostOpSizeCheck requires WithWALSizeLimit or WithWALPageLimit")
+	}
+	if c.limit == 0 && c.walLimit == 0 && c.pageLimit == 0 && c.rowLimit == 0 && c.interval == 0 && c.idle == 0 && c.pollPages == 0 && c.trigger == nil && c.cron == nil {
+		return nil, errors.New("sqlite: no checkpoint trigger, use WithLimit, WithWALSizeLimit, WithWALPageLimit, WithRowChangeLimit, WithInterval, WithIdleCheckpoint, WithSchedule or WithTrigger")
 	}
 
-	return &Checkpointer{
-		db:    db,
-		limit: limit,
-	}, nil
+	for _, db := range dbs {
+		if err := c.add(db); err != nil {
+			c.restoreAutocheckpoint()
+			c.closeConns()
+			return nil, err
+		}
+	}
+	if c.processLock {
+		if err := c.openProcessLock(); err != nil {
+			c.restoreAutocheckpoint()
+			c.closeConns()
+			return nil, err
+		}
+	}
+	if c.mode == 0 {
+		c.mode = Restart
+		if c.wal2 {
+			c.mode = Passive
+		}
+	}
+	if c.interval > 0 {
+		c.bg.Add(1)
+		go c.background()
+	}
+	if c.pollPages > 0 {
+		c.bg.Add(1)
+		go c.poll()
+	}
+	if c.cron != nil {
+		c.bg.Add(1)
+		go c.crontab()
+	}
+	if c.queue != nil {
+		go c.queue.work(c)
+	}
+	if c.idle > 0 {
+		c.touch()
+		c.lock()
+		c.idleTimer = c.clock.AfterFunc(c.idle, c.idleCheckpoint)
+		c.unlock()
+	}
+	return c, nil
+}
+
+// add checks and configures a database of the Checkpointer
+func (c *Checkpointer) add(h handle) error {
+	d := &database{q: h}
+	switch h := h.(type) {
+	case *sql.DB:
+		d.db = h
+	case *sql.Conn:
+		d.conn = h
+	}
+	if d.db == nil && d.conn == nil {
+		return ErrNilDB
+	}
+	db := d.q
+	version, err := c.checkVersion(db)
+	if err != nil {
+		return err
+	}
+	if len(c.dbs) == 0 {
+		c.version = version
+	}
+	if err := checkWritable(db); err != nil {
+		return err
+	}
+	if c.schema != "" {
+		if err := checkSchema(db, c.schema); err != nil {
+			return err
+		}
+	}
+	wal2, err := checkWAL(db, c.schema, c.enableWAL, c.enableWAL2)
+	if err != nil {
+		return err
+	}
+	if c.vacuumEvery > 0 {
+		if err := checkIncrementalVacuum(db); err != nil {
+			return err
+		}
+	}
+	if c.rowLimit > 0 {
+		if d.db != nil {
+			return errRowChangePool
+		}
+		if d.changes, err = totalChanges(context.Background(), db); err != nil {
+			return err
+		}
+	}
+	c.wal2 = wal2 && (len(c.dbs) == 0 || c.wal2)
+	if c.walLimit > 0 {
+		if d.walPath, err = walPath(db, c.schema); err != nil {
+			return err
+		}
+	}
+	// Every connection of the pool has the setting of the connector, see NewConnector
+	if d.db != nil {
+		_, d.managed = connectorPragma(d.db, "wal_autocheckpoint")
+		if !d.managed && !wal2 {
+			return ErrAutocheckpoint
+		}
+	}
+	if !d.managed {
+		if err := db.QueryRowContext(context.Background(), `pragma wal_autocheckpoint`).Scan(&d.autocheckpoint); err != nil {
+			return err
+		}
+	}
+	// From now on, newCheckpointer restores wal_autocheckpoint if it fails
+	c.dbs = append(c.dbs, d)
+	// WAL2 switches to the other WAL file when the current one reaches wal_autocheckpoint pages
+	if !d.managed && !wal2 {
+		if _, err := SetPragma(context.Background(), db, "wal_autocheckpoint", "0"); err != nil {
+			return err
+		}
+	}
+	if err := c.setup(context.Background(), db); err != nil {
+		return err
+	}
+	if c.journalSizeLimit >= 0 {
+		if err := c.checkJournalSizeLimit(db); err != nil {
+			return err
+		}
+	}
+	if c.managedPragmas {
+		if err := c.checkManagedPragmas(d, wal2); err != nil {
+			return err
+		}
+	}
+	if d.db == nil {
+		return nil
+	}
+	if c.holdOpen {
+		if err := pin(context.Background(), d); err != nil {
+			return err
+		}
+		c.stats.HoldOpenHealthy = true
+	}
+	if c.dedicated {
+		if d.conn, err = d.db.Conn(context.Background()); err != nil {
+			return err
+		}
+		return c.setup(context.Background(), d.conn)
+	}
+	return nil
+}
+
+// CheckpointResult is the outcome of a checkpoint as returned by pragma wal_checkpoint
+type CheckpointResult struct {
+	// Busy is true if the checkpoint could not complete because of concurrent readers or writers
+	Busy bool
+	// WALPages is the number of pages in the WAL, -1 if the database is not in WAL mode
+	WALPages int
+	// CheckpointedPages is the number of pages written back to the database, -1 if the database is not in WAL mode
+	CheckpointedPages int
+	// Mode is the mode of the checkpoint
+	Mode CheckpointMode
+	// Duration is the time taken by the pragma
+	Duration time.Duration
+	// Wait is the time spent waiting for the calls to Checkpoint in progress before the checkpoint
+	Wait time.Duration
+	// Err is the error of the checkpoint, see LastError
+	Err error
+
+	maintenance   error     // failure of the maintenance after the checkpoint, reported by notify
+	slowGuards    error     // see WithMaxGuardDuration, reported by notify
+	lowReclaim    error     // see WithMinReclaimPct, reported by notify
+	partialStreak int       // see WithPartialPolicy, reported by notify
+	skipped       int       // number of databases left out, see CheckpointFor, counted by save
+	durable       time.Time // start of a checkpoint that wrote back the whole WAL, see WithOnDurable
+}
+
+// Partial reports whether some pages of the WAL were not written back to the database,
+// because readers still use them or writers appended them meanwhile
+// It is the case of busy checkpoints but also of passive checkpoints, which are not reported as errors
+func (r CheckpointResult) Partial() bool {
+	return r.CheckpointedPages >= 0 && r.CheckpointedPages < r.WALPages
+}
+
+// LastResult returns the result of the last checkpoint
+func (c *Checkpointer) LastResult() CheckpointResult {
+	c.lock()
+	defer c.unlock()
+	return c.last
+}
+
+// CheckpointNow blocks until all functions that called Checkpoint are finished and performs a checkpoint
+// It does not reset the counter of Checkpoint
+func (c *Checkpointer) CheckpointNow() (CheckpointResult, error) {
+	c.lock()
+	c.exclude(context.Background())
+	res, err := c.checkpoint(context.Background(), c.mode)
+	c.settle()
+	c.unlock()
+	c.notify(res)
+	return res, err
+}
+
+// Close stops the background goroutines started by WithInterval and WithBusyRetry, waits for all functions that called Checkpoint
+// to finish and performs a final Truncate checkpoint so that the WAL file does not slow down the next start
+// With WithWriteQueue, it first runs the functions queued by Submit
+// It then restores wal_autocheckpoint to its value before NewCheckpointer, and the pragmas of IngestMode if it is still active
+// Afterwards, Checkpoint returns a no-op function and CheckpointContext returns ErrClosed
+// This is also the case once a checkpoint failed with ErrDBClosed, Close then only stops the goroutines
+// It can be called several times, only the first call does something
+func (c *Checkpointer) Close() error {
+	if c.queue != nil {
+		c.queue.close()
+	}
+	c.lock()
+	closed, terminated := c.closed, c.terminated
+	c.closed, c.terminated = true, false
+	c.g.block()
+	if c.delayed != nil {
+		c.delayed.Stop()
+	}
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	if c.backoffTimer != nil {
+		c.backoffTimer.Stop()
+	}
+	c.unlock()
+	if closed && !terminated {
+		return nil
+	}
+	defer c.subs.close()
+	close(c.stop)
+	c.bg.Wait()
+	if terminated {
+		return c.closeConns()
+	}
+	c.lock()
+	c.exclude(context.Background())
+	var ingestErr error
+	if c.ingest != nil {
+		ingestErr = c.restoreIngest(c.ingest)
+		c.ingest = nil
+	}
+	res, err := c.checkpoint(context.Background(), Truncate)
+	if err == nil {
+		err = ingestErr
+	}
+	if err2 := c.restoreAutocheckpoint(); err == nil {
+		err = err2
+	}
+	if err2 := c.closeConns(); err == nil {
+		err = err2
+	}
+	c.unlock()
+	c.notify(res)
+	return err
+}
+
+// restoreAutocheckpoint restores wal_autocheckpoint to its value before NewCheckpointer
+func (c *Checkpointer) restoreAutocheckpoint() error {
+	var err error
+	for _, d := range c.dbs {
+		if d.managed {
+			continue
+		}
+		if _, err2 := d.q.ExecContext(context.Background(), `pragma wal_autocheckpoint = `+strconv.Itoa(d.autocheckpoint)); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// Flush blocks until all functions that called Checkpoint are finished, performs a checkpoint
+// and resets the counter of Checkpoint as if the limit was reached
+func (c *Checkpointer) Flush() error {
+	_, err := c.force(context.Background(), c.mode)
+	return err
+}
+
+// Force is like Flush with the given mode, for instance Truncate before copying the database files
+// It returns ErrCheckpointBusy if the checkpoint could not complete
+// The functions calling Checkpoint meanwhile wait for it like for any other checkpoint
+func (c *Checkpointer) Force(mode CheckpointMode) error {
+	return c.ForceContext(context.Background(), mode)
+}
+
+// ForceContext is like Force but gives up when ctx is done
+func (c *Checkpointer) ForceContext(ctx context.Context, mode CheckpointMode) error {
+	if !mode.valid() {
+		return fmt.Errorf("sqlite: unknown checkpoint mode %d", int(mode))
+	}
+	_, err := c.force(ctx, mode)
+	return err
+}
+
+// force performs a checkpoint as if a trigger fired
+func (c *Checkpointer) force(ctx context.Context, mode CheckpointMode) (CheckpointResult, error) {
+	if err := c.lockContext(ctx); err != nil {
+		return CheckpointResult{}, err
+	}
+	if err := c.exclude(ctx); err != nil {
+		c.settle()
+		c.unlock()
+		return CheckpointResult{}, err
+	}
+	res, err := c.checkpoint(ctx, mode)
+	c.reset()
+	c.settle()
+	c.unlock()
+	c.notify(res)
+	return res, err
+}
+
+// LastError returns the error of the last checkpoint, nil if it succeeded or if none happened yet
+func (c *Checkpointer) LastError() error {
+	c.lock()
+	defer c.unlock()
+	return c.lastErr
 }
 
 // Checkpoint checks if the number of times it has been called reaches the limit
 // If it did, it blocks until all other functions that call it are finished and performs a checkpoint
+// so an operation wrapped by Checkpoint must not call it again, which deadlocks if a checkpoint is due meanwhile,
+// use TryCheckpoint for the nested operations instead
+// The operation can span several statements: it is counted once and the checkpoints wait for it to end, so that
+// wrapping a batch of statements in a single call, rather than each of them, neither over-counts nor lets a
+// checkpoint run in the middle of the batch, see also WithTx and CheckpointN
+// Failures are reported through LastError, WithLogger, WithErrorChannel and WithErrorHandler
+// The returned function can be called several times, only the first call ends the operation, see Stats.DoubleReleases
+// and Acquire
 // It is intended to be used like this:
 //
-// 	var c = sqlite.NewCheckPointer(db, 1000)
-// 	func() {
-// 		defer c.Checkpoint()()
-// 		db.Exec(`insert into "table" values ("value")`)
-// 	}
+//	var c, _ = sqlite.NewCheckpointer(db, sqlite.WithLimit(1000))
+//	func() {
+//		defer c.Checkpoint()()
+//		db.Exec(`insert into "table" values ("value")`)
+//	}
 func (c *Checkpointer) Checkpoint() func() {
-	c.m.Lock()
-	if c.i < c.limit {
-		c.i++
+	done, _ := c.CheckpointContext(context.Background())
+	return done
+}
+
+// CheckpointContext is like Checkpoint but gives up when ctx is done, whether it is waiting for an
+// in-progress checkpoint, for the other functions to finish or for its own checkpoint to complete
+// In that case it returns ctx.Err() and a no-op function, the call is not counted and
+// the checkpoint is left for the next call
+func (c *Checkpointer) CheckpointContext(ctx context.Context) (func(), error) {
+	done, _, err := c.acquire(ctx, 1)
+	return done, err
+}
+
+// CheckpointE is like Checkpoint but also returns the error of the checkpoint performed by this call, if any
+// With WithBusyRetry, it is the error of the first attempt
+func (c *Checkpointer) CheckpointE() (func(), error) {
+	done, res, _ := c.acquire(context.Background(), 1)
+	return done, res.Err
+}
+
+// CheckpointTriggered is like Checkpoint but also reports whether this call performed a checkpoint,
+// for instance to attribute its latency in a trace
+// It is false for a call that only waited for a checkpoint in progress, or whose checkpoint runs in the background
+// with WithConcurrentPassive
+func (c *Checkpointer) CheckpointTriggered() (triggered bool, done func()) {
+	done, res, _ := c.acquire(context.Background(), 1)
+	return res.Mode != 0, done
+}
+
+// CheckpointN is like Checkpoint for an operation weighing n calls, for instance the number of rows or pages it writes
+// The limit applies to the total weight of the calls since the last checkpoint, Checkpoint is CheckpointN(1)
+// A total weight overshooting the limit, by any margin, triggers a single checkpoint before the next call
+func (c *Checkpointer) CheckpointN(n uint) func() {
+	done, _, _ := c.acquire(context.Background(), uint64(n))
+	return done
+}
+
+// CheckpointWeighted is like CheckpointN for an operation whose weight is only known at its end,
+// for instance the number of rows it affected: the call weighs 1 until the returned function is called with its weight
+// Only the first call of the returned function does something
+func (c *Checkpointer) CheckpointWeighted() func(n uint) {
+	if c.isDrained() {
+		return func(uint) {}
+	}
+	done, _, err := c.acquire(context.Background(), 1)
+	if err != nil {
+		return func(uint) {}
+	}
+	var once sync.Once
+	return func(n uint) {
+		once.Do(func() {
+			if n > 1 {
+				atomic.AddUint64(&c.i, uint64(n-1))
+			}
+			done()
+		})
+	}
+}
+
+// acquire registers a call weighing n and performs a checkpoint first if one is due
+// The result has a zero mode if no checkpoint was performed
+// The error is ErrClosed or the error of ctx
+func (c *Checkpointer) acquire(ctx context.Context, n uint64) (func(), CheckpointResult, error) {
+	var res CheckpointResult
+	if c.isDrained() {
+		return nop, res, nil
+	}
+	var start time.Time
+	if c.trace.OnWriterBlocked != nil && atomic.LoadInt32(&c.throttled) != 0 {
+		start = time.Now()
+	}
+	if err := c.throttle(ctx); err != nil {
+		return nop, res, err
+	}
+	if c.fast(n) {
+		if !start.IsZero() {
+			c.trace.OnWriterBlocked(time.Since(start))
+		}
+		return c.done(), res, nil
+	}
+	if c.trace.OnWriterBlocked != nil {
+		if start.IsZero() {
+			start = time.Now()
+		}
+		defer func() { c.trace.OnWriterBlocked(time.Since(start)) }()
+	}
+	if err := c.lockContext(ctx); err != nil {
+		return nop, res, err
+	}
+	if c.closed {
+		c.unlock()
+		return nop, res, ErrClosed
+	}
+	if c.isDrained() {
+		c.unlock()
+		return nop, res, nil
+	}
+	due, err := c.due(n)
+	if due && c.debounced() {
+		due = false
+	}
+	if due && c.concurrent {
+		c.schedule()
+	} else if due && c.ceiling > 0 {
+		res, err = c.opportunistic(ctx)
+		if err != nil && ctx.Err() != nil {
+			c.unlock()
+			return nop, CheckpointResult{}, ctx.Err()
+		}
+	} else if due {
+		if err = c.excludeAuto(ctx); errors.Is(err, ErrGuardTimeout) {
+			// Reported by outcome
+			c.skip()
+		} else if err != nil {
+			c.pending = true
+			c.unlock()
+			return nop, res, err
+		} else {
+			res, err = c.checkpoint(ctx, c.autoMode())
+			if err != nil && ctx.Err() != nil {
+				c.pending = true
+				c.unlock()
+				return nop, CheckpointResult{}, ctx.Err()
+			}
+			c.reset()
+			c.settle()
+		}
+	}
+	// This call counts even if it performed the checkpoint, its operation comes after it
+	atomic.AddUint64(&c.i, n)
+	c.g.add()
+	c.unlock()
+	done := c.done()
+	defer releaseOnPanic(done)
+	c.outcome(res, err)
+	return done, res, nil
+}
+
+// TryCheckpoint is like Checkpoint but never waits for the other functions to finish:
+// if a checkpoint is due while some of them are still running, ok is false and
+// the checkpoint is left pending for the next call to Checkpoint or TryCheckpoint
+// If a checkpoint is in progress, ok is false and the call is not counted (done is a no-op),
+// so TryCheckpoint can wrap operations nested in an operation wrapped by Checkpoint
+func (c *Checkpointer) TryCheckpoint() (done func(), ok bool) {
+	if c.isDrained() {
+		return nop, true
+	}
+	if c.fast(1) {
+		return c.done(), true
+	}
+	for !c.tryLock() {
+		// The checkpoint in progress may be waiting for the operation calling TryCheckpoint
+		if c.g.isBlocked() {
+			return nop, false
+		}
+		runtime.Gosched()
+	}
+	if c.closed || c.isDrained() {
+		c.unlock()
+		return nop, true
+	}
+	var res CheckpointResult
+	due, err := c.due(1)
+	ok = true
+	if due && c.debounced() {
+		due = false
+	}
+	if due && c.concurrent {
+		c.schedule()
+	} else if due {
+		if c.g.block(); c.g.count() > 0 {
+			c.pending, ok = true, false
+		} else {
+			res, err = c.checkpoint(context.Background(), c.autoMode())
+			c.reset()
+			c.settle()
+		}
+	}
+	atomic.AddUint64(&c.i, 1)
+	c.g.add()
+	c.unlock()
+	done = c.done()
+	defer releaseOnPanic(done)
+	c.outcome(res, err)
+	return done, ok
+}
+
+// fast registers a call weighing n without taking the lock, it fails if a checkpoint is due or in progress
+func (c *Checkpointer) fast(n uint64) bool {
+	c.touch()
+	if !c.g.enter() {
+		return false
+	}
+	i := atomic.AddUint64(&c.i, n)
+	limit := c.effectiveLimit()
+	if (limit > 0 && i > limit && !c.held()) || (c.sampled() && sizeCheck(i-n, n)) || (!c.held() && c.triggered(i)) ||
+		(!c.held() && atomic.LoadInt32(&c.armed) != 0) {
+		// Let the locked path check the triggers
+		atomic.AddUint64(&c.i, ^(n - 1))
+		c.g.leave()
+		return false
+	}
+	return true
+}
+
+// due reports whether a checkpoint must be performed before a call weighing n, the caller must hold the lock
+func (c *Checkpointer) due(n uint64) (bool, error) {
+	if c.held() {
+		return false, nil
+	}
+	i := atomic.LoadUint64(&c.i)
+	full, err := c.walFull(i, n)
+	limit := c.effectiveLimit()
+	return full || c.pending || atomic.LoadInt32(&c.armed) != 0 || (limit > 0 && i >= limit) || c.triggered(i+n), err
+}
+
+// exclude blocks new calls and waits for the calls in progress, the caller must hold the lock and call settle afterwards
+func (c *Checkpointer) exclude(ctx context.Context) error {
+	start := time.Now()
+	c.g.block()
+	var err error
+	if c.maxGuard > 0 {
+		err = c.waitGuards(ctx)
+	} else {
+		err = c.g.wait(ctx)
+	}
+	c.waited = time.Since(start)
+	return err
+}
+
+// settle unblocks new calls unless a checkpoint is pending or the Checkpointer is closed, the caller must hold the lock
+func (c *Checkpointer) settle() {
+	if !c.pending && !c.closed {
+		c.g.unblock()
+	}
+}
+
+// reset starts a new cycle of triggers after a checkpoint, the caller must hold the lock
+func (c *Checkpointer) reset() {
+	c.written += atomic.SwapUint64(&c.i, 0)
+	c.pending = false
+	atomic.StoreInt32(&c.armed, 0)
+}
+
+// done returns the function that ends a call to Checkpoint, only its first call does something
+// so that calling it twice by mistake does not release another call, the next calls are counted
+func (c *Checkpointer) done() func() {
+	var released int32
+	if c.guards != nil {
+		untrack := c.guards.track()
+		return func() {
+			if !atomic.CompareAndSwapInt32(&released, 0, 1) {
+				c.doubleRelease()
+				return
+			}
+			untrack()
+			c.g.leave()
+			c.postOp()
+		}
+	}
+	return func() {
+		if !atomic.CompareAndSwapInt32(&released, 0, 1) {
+			c.doubleRelease()
+			return
+		}
+		c.g.leave()
+		c.postOp()
+	}
+}
+
+// report sends a failure to the logger, the error channel and the error handler
+// res is the result of the checkpoint that failed, if any
+func (c *Checkpointer) report(res CheckpointResult, err error) {
+	if c.logger != nil {
+		if res.Mode == 0 {
+			c.logger.Error("checkpointer failed", "err", err)
+		} else {
+			c.logger.Error("checkpoint failed",
+				"mode", res.Mode,
+				"duration", res.Duration,
+				"wait", res.Wait,
+				"busy", res.Busy,
+				"wal_pages", res.WALPages,
+				"checkpointed_pages", res.CheckpointedPages,
+				"err", err,
+			)
+		}
+	}
+	if c.errCh != nil {
+		select {
+		case c.errCh <- err:
+		default:
+		}
+	}
+	if c.onError != nil {
+		if errs, ok := err.(multiError); ok {
+			for _, err := range errs {
+				c.onError(err)
+			}
+		} else {
+			c.onError(err)
+		}
+	}
+}
+
+func nop() {}
+
+// releaseOnPanic calls done if the caller is panicking, it must be deferred
+func releaseOnPanic(done func()) {
+	if r := recover(); r != nil {
+		done()
+		panic(r)
+	}
+}
+
+func (c *Checkpointer) lock() {
+	c.m <- struct{}{}
+}
+
+func (c *Checkpointer) lockContext(ctx context.Context) error {
+	select {
+	case c.m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Checkpointer) tryLock() bool {
+	select {
+	case c.m <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Checkpointer) unlock() {
+	<-c.m
+}
+
+// checkpoint checkpoints every database and records the outcome, the caller must hold the lock and wait for the writers
+func (c *Checkpointer) checkpoint(ctx context.Context, mode CheckpointMode) (CheckpointResult, error) {
+	return c.checkpointSelection(ctx, mode, c.selection(false))
+}
+
+// checkpointSelection is checkpoint restricted to sel, see CheckpointSchemas
+func (c *Checkpointer) checkpointSelection(ctx context.Context, mode CheckpointMode, sel selection) (CheckpointResult, error) {
+	c.keepOpen(ctx)
+	start := c.clock.Now()
+	c.lastAt = start
+	ctx = c.startTrace(ctx)
+	var (
+		res CheckpointResult
+		err error
+	)
+	if c.truncating {
+		c.truncating = false
+		res, err = c.truncate(ctx, sel)
 	} else {
-		c.i = 0
-		c.wg.Wait()
-		var failed bool
-		if err := c.db.QueryRow(`pragma wal_checkpoint(restart)`).Scan(&failed, new(uint), new(uint)); err != nil {
-			log.Println("checkpointing:", err)
-		} else if failed {
-			log.Println("checkpointing failed")
-		}
-	}
-	c.wg.Add(1)
-	c.m.Unlock()
-	return c.wg.Done
+		res, err = c.run(ctx, mode, sel)
+	}
+	res.Wait, c.waited = c.waited, 0
+	res.slowGuards, c.slowGuards = c.slowGuards, nil
+	c.save(start, res, err)
+	res.lowReclaim, c.lowReclaim = c.lowReclaim, nil
+	res.partialStreak, c.partialStreak = c.partialStreak, 0
+	if err == nil {
+		if c.rowLimit > 0 {
+			c.rebaseChanges(ctx)
+		}
+		if !res.Busy && !res.Partial() && res.WALPages >= 0 {
+			res.durable = start
+		}
+		res.maintenance = c.maintain(ctx)
+	}
+	if c.trace.OnCheckpointEnd != nil {
+		c.trace.OnCheckpointEnd(ctx, res)
+	}
+	return res, err
+}
+
+// save records the outcome of a checkpoint started at start, the caller must hold the lock
+func (c *Checkpointer) save(start time.Time, res CheckpointResult, err error) {
+	c.stats.SkippedDatabases += uint64(res.skipped)
+	c.last, c.lastErr = res, err
+	c.doneAt = c.clock.Now()
+	atomic.StoreInt64(&c.lastEnd, c.doneAt.UnixNano())
+	atomic.StoreInt64(&c.lastPages, int64(res.WALPages))
+	c.record(start, res, err)
+	c.remember(res)
+	c.pressure(res, err)
+	c.observe(start, res, err)
+	c.adapt(res, err)
+	c.countPartial(res, err)
+	c.tune(res, err)
+	c.backoff(err)
+	// Checkpointing a closed database fails forever
+	if errors.Is(err, ErrDBClosed) {
+		c.closed, c.terminated = true, true
+		c.g.block()
+	}
+}
+
+// run checkpoints every database after calling the function set by WithBeforeCheckpoint
+// With several databases, the results are summed and the errors are combined
+// run does not need the lock, so that WithConcurrentPassive calls it without: it only reads the configuration,
+// immutable once the Checkpointer is constructed, and the fields accessed atomically, the selection is decided
+// by the caller under the lock and the outcome is recorded by save
+func (c *Checkpointer) run(ctx context.Context, mode CheckpointMode, sel selection) (res CheckpointResult, err error) {
+	if c.serial != nil {
+		select {
+		case c.serial <- struct{}{}:
+			defer func() { <-c.serial }()
+		case <-ctx.Done():
+			return CheckpointResult{Mode: mode, Err: ctx.Err()}, ctx.Err()
+		}
+	}
+	if c.lockFile != nil {
+		if err := c.lockProcess(ctx); err != nil {
+			// The lock is shared by the databases
+			res := CheckpointResult{Mode: mode, Busy: errors.Is(err, ErrPeerCheckpointing), WALPages: -1, CheckpointedPages: -1}
+			res.Err = newCheckpointError(0, false, res, err)
+			return res, res.Err
+		}
+		defer unlockFile(c.lockFile)
+	}
+	if c.beforeCheckpoint != nil {
+		c.beforeCheckpoint()
+	}
+	atomic.AddInt32(&c.running, 1)
+	start := time.Now()
+	defer func() {
+		atomic.AddInt32(&c.running, -1)
+		res.Duration = time.Since(start)
+		res.Err = err
+	}()
+	schema := c.schema
+	if sel.schema != "" {
+		schema = sel.schema
+	}
+	if len(c.dbs) == 1 || sel.only != nil {
+		i := 0
+		for sel.only != nil && c.dbs[i] != sel.only {
+			i++
+		}
+		res, err := c.checkpointDB(ctx, c.dbs[i], schema, mode)
+		if err != nil {
+			return res, newCheckpointError(i, len(c.dbs) > 1, res, err)
+		}
+		return res, nil
+	}
+	res = CheckpointResult{WALPages: -1, CheckpointedPages: -1, Mode: mode}
+	var errs multiError
+	for i, d := range c.dbs {
+		if sel.skips(d) {
+			res.skipped++
+			continue
+		}
+		r, err := c.checkpointDB(ctx, d, schema, mode)
+		res.add(r)
+		if err != nil {
+			errs = append(errs, newCheckpointError(i, true, r, err))
+		} else {
+			atomic.StoreUint64(&d.writes, 0)
+		}
+	}
+	return res, errs.err()
+}
+
+// checkpoint runs the pragma on db
+func checkpoint(ctx context.Context, db queryer, schema string, mode CheckpointMode) (res CheckpointResult, err error) {
+	res.Mode = mode
+	var busy bool
+	var walPages, checkpointedPages int
+	query, err := checkpointPragma(schema, mode)
+	if err != nil {
+		return res, err
+	}
+	if err := db.QueryRowContext(ctx, query).Scan(&busy, &walPages, &checkpointedPages); err != nil {
+		return res, checkpointError(err)
+	}
+	res.Busy, res.WALPages, res.CheckpointedPages = busy, walPages, checkpointedPages
+	// A passive checkpoint never waits, so not being able to checkpoint every frame is expected
+	if res.Busy && mode != Passive {
+		return res, ErrCheckpointBusy
+	}
+	return res, nil
+}
+
+// checkpointError wraps the error of a checkpoint, with ErrDBClosed if it comes from a closed database or connection
+// (database/sql does not export the error of a closed database)
+func checkpointError(err error) error {
+	if errors.Is(err, sql.ErrConnDone) || strings.Contains(err.Error(), "sql: database is closed") {
+		return fmt.Errorf("checkpointing: %w: %v", ErrDBClosed, err)
+	}
+	return fmt.Errorf("checkpointing: %w", err)
 }
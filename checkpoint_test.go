@@ -0,0 +1,264 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckpointPanic(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(2))
+	for i := 0; i < 5; i++ {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			defer c.Checkpoint()()
+			insert(t, db)
+			panic("operation failed")
+		}()
+	}
+	done := c.Checkpoint()
+	done()
+	done()
+	if n := c.Stats().DoubleReleases; n != 1 {
+		t.Errorf("DoubleReleases = %d, want 1", n)
+	}
+	if n := c.PendingWriters(); n != 0 {
+		t.Fatalf("PendingWriters = %d after the panics", n)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.CheckpointNow()
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the checkpoint waits for the operations that panicked")
+	}
+	if n := c.Stats().TotalCheckpoints; n < 3 {
+		t.Errorf("TotalCheckpoints = %d, want at least 3", n)
+	}
+}
+
+func TestCheckpointExcludesWriters(t *testing.T) {
+	db := openTest(t, "test.db")
+	var active, overlaps int32
+	c := newTest(t, db, WithLimit(10), WithBeforeCheckpoint(func() {
+		if atomic.LoadInt32(&active) != 0 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				done := c.Checkpoint()
+				atomic.AddInt32(&active, 1)
+				_, err := db.Exec(`insert into t values (1)`)
+				atomic.AddInt32(&active, -1)
+				done()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&overlaps); n != 0 {
+		t.Errorf("%d checkpoints ran along with an operation", n)
+	}
+	if c.Stats().TotalCheckpoints == 0 {
+		t.Error("no checkpoint")
+	}
+}
+
+// BenchmarkCheckpointParallel measures the calls to Checkpoint, serial or parallel, without reaching the limit
+// or reaching it every 1000 calls
+func BenchmarkCheckpointParallel(b *testing.B) {
+	for _, bb := range []struct {
+		name  string
+		limit uint
+	}{
+		{"NoLimitHit", 1 << 30},
+		{"LimitHit", 1000},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.Run("Serial", func(b *testing.B) {
+				c := newTest(b, openTest(b, "test.db"), WithLimit(bb.limit))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					c.Checkpoint()()
+				}
+			})
+			b.Run("Parallel", func(b *testing.B) {
+				c := newTest(b, openTest(b, "test.db"), WithLimit(bb.limit))
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						c.Checkpoint()()
+					}
+				})
+			})
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	for _, tt := range []struct {
+		limit uint
+		want  []int // calls performing a checkpoint among the first 10
+	}{
+		{1, []int{2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{2, []int{3, 5, 7, 9}},
+		{3, []int{4, 7, 10}},
+		{4, []int{5, 9}},
+		{10, nil},
+	} {
+		db := openTest(t, "test.db")
+		c := newTest(t, db, WithLimit(tt.limit))
+		var got []int
+		for call := 1; call <= 10; call++ {
+			before := c.Stats().TotalCheckpoints
+			done := c.Checkpoint()
+			if c.Stats().TotalCheckpoints > before {
+				got = append(got, call)
+			}
+			insert(t, db)
+			done()
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("limit %d: checkpoints at the calls %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCheckpointThunderingHerd(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(100))
+	var wg sync.WaitGroup
+	for i := 0; i < 4000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				done := c.Checkpoint()
+				if i%100 == 0 {
+					if _, err := db.Exec(`insert into t values (1)`); err != nil {
+						t.Error(err)
+					}
+				}
+				done()
+			}
+		}(i)
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Minute):
+		t.Fatalf("deadlock, %d calls in progress", c.PendingWriters())
+	}
+	if n := c.PendingWriters(); n != 0 {
+		t.Errorf("PendingWriters = %d", n)
+	}
+	// The checkpoints are serialized, the calls arriving while one is due wait for it instead of triggering another
+	if s := c.Stats(); s.TotalCheckpoints == 0 || s.TotalCheckpoints > 20000/100 {
+		t.Errorf("TotalCheckpoints = %d, want between 1 and 200", s.TotalCheckpoints)
+	}
+}
+
+func TestCheckpointClosedDB(t *testing.T) {
+	db := openTest(t, "test.db")
+	onError, reported := errorsOf()
+	c := newTest(t, db, WithLimit(5), onError)
+	for i := 0; i < 12; i++ {
+		if i == 7 {
+			db.Close()
+		}
+		done := c.Checkpoint()
+		db.Exec(`insert into t values (1)`)
+		done()
+	}
+	if errs := reported(); len(errs) != 1 || !errors.Is(errs[0], ErrDBClosed) {
+		t.Fatalf("reported %v, want a single ErrDBClosed", errs)
+	}
+	if !errors.Is(c.LastError(), ErrDBClosed) {
+		t.Errorf("LastError = %v, want ErrDBClosed", c.LastError())
+	}
+	// Terminal state: no more checkpoint, the calls are not counted anymore
+	s := c.Stats()
+	for i := 0; i < 20; i++ {
+		c.Checkpoint()()
+	}
+	if _, err := c.CheckpointContext(context.Background()); err != ErrClosed {
+		t.Errorf("CheckpointContext returned %v, want ErrClosed", err)
+	}
+	if n := c.Stats().TotalCheckpoints; n != s.TotalCheckpoints {
+		t.Errorf("%d checkpoints after the failure", n-s.TotalCheckpoints)
+	}
+	if n := c.PendingWriters(); n != 0 {
+		t.Errorf("PendingWriters = %d", n)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestZeroLimit(t *testing.T) {
+	db := openTest(t, "test.db")
+	// No default limit: a Checkpointer without trigger is an error, whether the limit is 0 or not given
+	if _, err := NewCheckpointer(db); err == nil {
+		t.Error("no error without trigger")
+	}
+	if _, err := NewCheckpointer(db, WithLimit(0)); err == nil {
+		t.Error("no error with a zero limit and no other trigger")
+	}
+	if _, err := NewCheckPointer(db, 0); err == nil {
+		t.Error("no error from NewCheckPointer with a zero limit")
+	}
+	// A zero limit disables the trigger on the number of calls, the others still work
+	c := newTest(t, db, WithLimit(0), WithWALPageLimit(1<<30))
+	for i := 0; i < 100; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	if n := c.Stats().TotalCheckpoints; n != 0 {
+		t.Errorf("%d checkpoints with a zero limit", n)
+	}
+	if n, _ := c.SinceLastCheckpoint(); n != 100 {
+		t.Errorf("%d calls counted, want 100", n)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Stats().TotalCheckpoints; n != 1 {
+		t.Errorf("%d checkpoints after Flush, want 1", n)
+	}
+	// SetLimit enables it afterwards
+	c.SetLimit(10)
+	for i := 0; i < 11; i++ {
+		c.Checkpoint()()
+	}
+	if n := c.Stats().TotalCheckpoints; n != 2 {
+		t.Errorf("%d checkpoints after SetLimit, want 2", n)
+	}
+}
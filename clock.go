@@ -0,0 +1,45 @@
+package sqlite
+
+import "time"
+
+// clock is the source of time of the time-based triggers: WithInterval, WithSchedule, WithIdleCheckpoint,
+// WithDebounce and ScheduleCheckpoint, so that a fake one can be injected to exercise them deterministically
+// The durations of the checkpoints are measured with the real time
+type clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) timer
+	AfterFunc(d time.Duration, f func()) timer
+}
+
+// timer is a time.Timer of a clock
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock is the clock of the time package, the default one
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) timer { return realTimer{time.NewTimer(d)} }
+
+func (realClock) AfterFunc(d time.Duration, f func()) timer { return realTimer{time.AfterFunc(d, f)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// withClock sets the clock of the time-based triggers, for the tests
+func withClock(clk clock) Option {
+	return func(c *Checkpointer) error {
+		c.clock = clk
+		return nil
+	}
+}
+
+// since returns the time elapsed since t according to the clock of c
+func (c *Checkpointer) since(t time.Time) time.Duration {
+	return c.clock.Now().Sub(t)
+}
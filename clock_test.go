@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only changes with Advance
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+type fakeTimer struct {
+	clk    *fakeClock
+	c      chan time.Time
+	f      func()
+	when   time.Time
+	active bool
+	fired  bool // the timer of NewTimer fired and was not reset or stopped since
+}
+
+func (clk *fakeClock) Now() time.Time {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	return clk.now
+}
+
+func (clk *fakeClock) NewTimer(d time.Duration) timer {
+	return clk.timer(d, nil)
+}
+
+func (clk *fakeClock) AfterFunc(d time.Duration, f func()) timer {
+	return clk.timer(d, f)
+}
+
+func (clk *fakeClock) timer(d time.Duration, f func()) *fakeTimer {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	t := &fakeTimer{clk: clk, c: make(chan time.Time, 1), f: f, when: clk.now.Add(d), active: true}
+	clk.timers = append(clk.timers, t)
+	return t
+}
+
+// Advance moves the time forward by d and fires the timers that expire, the functions of AfterFunc
+// are called by Advance, then it waits for the goroutines to reset or stop the timers of NewTimer that fired
+func (clk *fakeClock) Advance(tb testing.TB, d time.Duration) {
+	tb.Helper()
+	clk.mu.Lock()
+	clk.now = clk.now.Add(d)
+	var funcs []func()
+	for _, t := range clk.timers {
+		if !t.active || t.when.After(clk.now) {
+			continue
+		}
+		t.active = false
+		if t.f != nil {
+			funcs = append(funcs, t.f)
+		} else {
+			t.fired = true
+			t.c <- clk.now
+		}
+	}
+	clk.mu.Unlock()
+	for _, f := range funcs {
+		f()
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		clk.mu.Lock()
+		fired := false
+		for _, t := range clk.timers {
+			fired = fired || t.fired
+		}
+		clk.mu.Unlock()
+		if !fired {
+			return
+		}
+		if time.Now().After(deadline) {
+			tb.Fatal("a fired timer is not reset")
+		}
+	}
+}
+
+// wait waits for n timers to be created, by the goroutines started by NewCheckpointer for instance
+func (clk *fakeClock) wait(tb testing.TB, n int) {
+	tb.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		clk.mu.Lock()
+		created := len(clk.timers)
+		clk.mu.Unlock()
+		if created >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			tb.Fatalf("%d timers created, want %d", created, n)
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+	active := t.active
+	t.active, t.fired = false, false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+	active := t.active
+	t.when, t.active, t.fired = t.clk.now.Add(d), true, false
+	return active
+}
+
+// checkpoints fails the test if c did not perform n checkpoints
+func checkpoints(tb testing.TB, c *Checkpointer, n uint64) {
+	tb.Helper()
+	if got := c.Stats().TotalCheckpoints; got != n {
+		tb.Fatalf("%d checkpoints, want %d", got, n)
+	}
+}
+
+func TestIntervalFakeClock(t *testing.T) {
+	clk := newFakeClock()
+	c := newTest(t, openTest(t, "test.db"), withClock(clk), WithInterval(time.Minute))
+	clk.wait(t, 1)
+	c.Checkpoint()()
+	clk.Advance(t, 30*time.Second)
+	checkpoints(t, c, 0)
+	clk.Advance(t, 30*time.Second)
+	checkpoints(t, c, 1)
+	// No call since the last checkpoint
+	clk.Advance(t, time.Minute)
+	checkpoints(t, c, 1)
+	c.Checkpoint()()
+	clk.Advance(t, time.Minute)
+	checkpoints(t, c, 2)
+}
+
+func TestIdleFakeClock(t *testing.T) {
+	clk := newFakeClock()
+	c := newTest(t, openTest(t, "test.db"), withClock(clk), WithIdleCheckpoint(10*time.Second))
+	c.Checkpoint()()
+	clk.Advance(t, 5*time.Second)
+	// Each call restarts the delay
+	c.Checkpoint()()
+	clk.Advance(t, 5*time.Second)
+	checkpoints(t, c, 0)
+	clk.Advance(t, 5*time.Second)
+	checkpoints(t, c, 1)
+	if mode := c.Stats().LastMode; mode != Truncate {
+		t.Errorf("idle checkpoint in %v mode, want truncate", mode)
+	}
+	// No call since the last checkpoint
+	clk.Advance(t, 10*time.Second)
+	checkpoints(t, c, 1)
+}
+
+func TestDebounceFakeClock(t *testing.T) {
+	clk := newFakeClock()
+	c := newTest(t, openTest(t, "test.db"), withClock(clk), WithLimit(2), WithDebounce(10*time.Second))
+	for i := 0; i < 3; i++ {
+		c.Checkpoint()()
+	}
+	checkpoints(t, c, 1)
+	// The limit is reached again right after the checkpoint
+	for i := 0; i < 2; i++ {
+		c.Checkpoint()()
+	}
+	checkpoints(t, c, 1)
+	if n := c.Stats().DebouncedCheckpoints; n != 1 {
+		t.Fatalf("%d debounced checkpoints, want 1", n)
+	}
+	clk.Advance(t, 10*time.Second)
+	for i := 0; i < 2; i++ {
+		c.Checkpoint()()
+	}
+	checkpoints(t, c, 2)
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+// WithConcurrentPassive makes the checkpoints triggered by Checkpoint and TryCheckpoint run in a goroutine
+// with the Passive mode, so that the calls never wait for them, at the cost of a larger WAL
+// The counter is reset when the checkpoint is scheduled and at most one of them runs at a time,
+// the checkpoints that are due meanwhile are skipped
+// Flush, Force, CheckpointNow, Close and the other triggers still wait for the calls in progress
+func WithConcurrentPassive() Option {
+	return func(c *Checkpointer) error {
+		c.concurrent = true
+		return nil
+	}
+}
+
+// schedule resets the counter and starts a passive checkpoint unless one is running, the caller must hold the lock
+func (c *Checkpointer) schedule() {
+	// Before the reset, which the attribution of the calls depends on
+	sel := c.selection(true)
+	c.reset()
+	c.settle()
+	if c.scheduled {
+		return
+	}
+	c.scheduled = true
+	c.lastAt = c.clock.Now()
+	c.bg.Add(1)
+	go c.passive(sel)
+}
+
+// passive performs the checkpoint started by schedule, without the lock, see run
+func (c *Checkpointer) passive(sel selection) {
+	defer c.bg.Done()
+	start := time.Now()
+	res, err := c.run(context.Background(), Passive, sel)
+	c.lock()
+	c.save(start, res, err)
+	c.scheduled = false
+	c.unlock()
+	c.outcome(res, err)
+}
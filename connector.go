@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Pragma is a pragma set by NewConnector on every connection
+type Pragma struct {
+	Name, Value string
+}
+
+// NewConnector returns a connector setting the pragmas on every connection opened by base before database/sql
+// uses it, for instance with Pragma{"wal_autocheckpoint", "0"} so that no connection of the pool
+// of sql.OpenDB checkpoints automatically, whatever its size
+// The pragmas must be the settings of each connection listed below, with a keyword or an integer as value:
+// analysis_limit, auto_vacuum, automatic_index, busy_timeout, cache_size, cache_spill, cell_size_check,
+// defer_foreign_keys, foreign_keys, journal_mode, journal_size_limit, locking_mode, mmap_size, query_only,
+// recursive_triggers, secure_delete, synchronous, temp_store and wal_autocheckpoint
+// Otherwise every connection fails with the error of the first invalid pragma
+// NewCheckpointer leaves wal_autocheckpoint alone on a database opened with a connector setting it
+func NewConnector(base driver.Connector, pragmas ...Pragma) driver.Connector {
+	c := &connector{base: base, pragmas: pragmas}
+	for _, p := range pragmas {
+		if c.err = p.validate(); c.err != nil {
+			break
+		}
+	}
+	c.driver = connectorDriver{base.Driver(), c}
+	return c
+}
+
+type connector struct {
+	base    driver.Connector
+	driver  connectorDriver
+	pragmas []Pragma
+	err     error  // error of the first invalid pragma
+	txLock  string // locking mode of the transactions of Open, see WithTxLock
+}
+
+// connectorDriver is the driver of a connector, NewCheckpointer finds the connector through sql.DB.Driver
+type connectorDriver struct {
+	driver.Driver
+	c *connector
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range c.pragmas {
+		if err := execConn(ctx, conn, `pragma `+p.Name+` = `+p.Value); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("sqlite: setting pragma %s: %w", p.Name, err)
+		}
+	}
+	return conn, nil
+}
+
+// execConn executes a query without arguments on a driver connection
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if e, ok := conn.(driver.ExecerContext); ok {
+		_, err := e.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	// Exec is deprecated but the only method every driver.Stmt has
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// immediate reports whether the transactions of db take the write lock when they begin,
+// which is only known for the databases opened by Open, see WithTxLock
+func immediate(db handle) bool {
+	if db, ok := db.(*sql.DB); ok {
+		if d, ok := db.Driver().(connectorDriver); ok {
+			return d.c.txLock == "immediate" || d.c.txLock == "exclusive"
+		}
+	}
+	return false
+}
+
+// connectorPragma returns the value set by the connector of db for the pragma name, if any
+func connectorPragma(db *sql.DB, name string) (string, bool) {
+	d, ok := db.Driver().(connectorDriver)
+	if !ok {
+		return "", false
+	}
+	for _, p := range d.c.pragmas {
+		if strings.EqualFold(p.Name, name) {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithSchedule performs a Truncate checkpoint at the times of spec, in the local time zone, whatever the number of calls:
+// either "HH:MM" for every day or a cron expression of 5 fields (minute, hour, day of month, month and day of week,
+// 0 being Sunday), each field being * or a list of numbers and ranges, optionally followed by a step such as */15
+// Like the other checkpoints, it waits for the calls in progress, see WithGuardTimeout
+// The goroutine is stopped by Close
+func WithSchedule(spec string) Option {
+	return func(c *Checkpointer) error {
+		s, err := parseCron(spec)
+		if err != nil {
+			return err
+		}
+		c.cron = s
+		return nil
+	}
+}
+
+// cron is a parsed schedule, each field is a set of allowed values
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDOM, anyDOW                bool
+}
+
+func parseCron(spec string) (*cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 1 {
+		if hh, mm, ok := strings.Cut(fields[0], ":"); ok {
+			fields = []string{mm, hh, "*", "*", "*"}
+		}
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("sqlite: invalid schedule %q, expecting HH:MM or 5 fields", spec)
+	}
+	var s cron
+	for i, f := range []struct {
+		set      *uint64
+		min, max int
+	}{{&s.minute, 0, 59}, {&s.hour, 0, 23}, {&s.dom, 1, 31}, {&s.month, 1, 12}, {&s.dow, 0, 7}} {
+		set, err := parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("sqlite: invalid schedule %q: %w", spec, err)
+		}
+		*f.set = set
+	}
+	// 7 is Sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.anyDOM, s.anyDOW = fields[2] == "*", fields[4] == "*"
+	return &s, nil
+}
+
+// parseCronField parses a comma-separated list of *, n or n-m, each followed by an optional /step
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		r, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			r = part[:i]
+		}
+		lo, hi := min, max
+		if r != "*" {
+			a, b, isRange := strings.Cut(r, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// next returns the first time matching the schedule strictly after t, the zero time if none is found within 5 years
+func (s *cron) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.day(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// day reports whether the day of t matches, either of the day of month and the day of week matching
+// if both are restricted, like in cron
+func (s *cron) day(t time.Time) bool {
+	dom, dow := s.dom&(1<<t.Day()) != 0, s.dow&(1<<int(t.Weekday())) != 0
+	if s.anyDOM || s.anyDOW {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *Checkpointer) crontab() {
+	defer c.bg.Done()
+	for {
+		next := c.cron.next(c.clock.Now())
+		if next.IsZero() {
+			return
+		}
+		t := c.clock.NewTimer(-c.since(next))
+		select {
+		case <-c.stop:
+			t.Stop()
+			return
+		case <-t.C():
+			c.scheduledCheckpoint()
+		}
+	}
+}
+
+// scheduledCheckpoint performs the Truncate checkpoint of WithSchedule
+func (c *Checkpointer) scheduledCheckpoint() {
+	c.lock()
+	if c.closed {
+		c.unlock()
+		return
+	}
+	if err := c.excludeAuto(context.Background()); err != nil {
+		c.skip()
+		c.unlock()
+		c.report(CheckpointResult{}, err)
+		return
+	}
+	res, err := c.checkpoint(context.Background(), Truncate)
+	c.reset()
+	c.settle()
+	c.unlock()
+	c.outcome(res, err)
+}
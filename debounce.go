@@ -0,0 +1,30 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithDebounce skips the automatic checkpoints triggered less than d after the end of a successful checkpoint,
+// for instance by the limit right after WithInterval, resetting the triggers as if they were performed
+// Flush, Force and the checkpoints following a failure are never skipped, see Stats.DebouncedCheckpoints
+func WithDebounce(d time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if d <= 0 {
+			return fmt.Errorf("sqlite: invalid debounce duration %v", d)
+		}
+		c.debounce = d
+		return nil
+	}
+}
+
+// debounced skips a due checkpoint if the last one succeeded less than the debounce duration ago,
+// the caller must hold the lock
+func (c *Checkpointer) debounced() bool {
+	if c.debounce == 0 || c.lastErr != nil || c.doneAt.IsZero() || c.since(c.doneAt) >= c.debounce {
+		return false
+	}
+	c.stats.DebouncedCheckpoints++
+	c.skip()
+	return true
+}
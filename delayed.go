@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduleCheckpoint performs an automatic checkpoint after delay, for instance after a known burst of writes
+// so that it is checkpointed soon but not synchronously
+// Calling it again before the checkpoint restarts the delay instead of scheduling another checkpoint,
+// so that the writes of the window share a single checkpoint
+// The checkpoint waits for the calls to Checkpoint in progress like the other ones, it is skipped
+// while Pause is in effect and after Close
+func (c *Checkpointer) ScheduleCheckpoint(delay time.Duration) {
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return
+	}
+	if c.delayed != nil && c.delayed.Stop() {
+		c.delayed.Reset(delay)
+		return
+	}
+	c.delayed = c.clock.AfterFunc(delay, c.delayedCheckpoint)
+}
+
+func (c *Checkpointer) delayedCheckpoint() {
+	c.lock()
+	if c.closed || c.held() {
+		c.unlock()
+		return
+	}
+	if err := c.excludeAuto(context.Background()); err != nil {
+		c.skip()
+		c.unlock()
+		c.report(CheckpointResult{}, err)
+		return
+	}
+	res, err := c.checkpoint(context.Background(), c.autoMode())
+	c.reset()
+	c.settle()
+	c.unlock()
+	c.outcome(res, err)
+}
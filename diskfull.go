@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WithOnDiskFull calls fn with the error of each checkpoint failing because the disk is full
+// or because of an I/O error, after the checkpoint, outside the lock, see WithDiskFullBackoff
+// The errors are still reported like the other failures
+func WithOnDiskFull(fn func(error)) Option {
+	return func(c *Checkpointer) error {
+		c.onDiskFull = fn
+		return nil
+	}
+}
+
+// WithDiskFullBackoff holds off the automatic checkpoints for min after a checkpoint failing because the disk is full
+// or because of an I/O error, doubling the delay after each such failure in a row up to max,
+// so that the checkpoints do not hammer a full disk
+// They resume once a checkpoint succeeds, Force and the other explicit checkpoints are not held off,
+// see Diagnostics.DiskFull
+func WithDiskFullBackoff(min, max time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("sqlite: invalid disk full backoff (min %v, max %v)", min, max)
+		}
+		c.backoffMin, c.backoffMax = min, max
+		return nil
+	}
+}
+
+// isDiskFull reports whether err is a disk full or I/O error
+// The error codes depend on the driver, so the messages of SQLite are matched instead
+func isDiskFull(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database or disk is full") || strings.Contains(msg, "disk I/O error")
+}
+
+// backoff holds off the automatic checkpoints after a disk full error and resumes them after a success,
+// see WithDiskFullBackoff, the caller must hold the lock
+func (c *Checkpointer) backoff(err error) {
+	switch {
+	case c.backoffMin == 0:
+	case isDiskFull(err):
+		if c.backoffTimer != nil {
+			// A Force during the backoff, the delay goes on
+			return
+		}
+		if c.backoffDelay == 0 {
+			c.backoffDelay = c.backoffMin
+		} else if c.backoffDelay *= 2; c.backoffDelay > c.backoffMax {
+			c.backoffDelay = c.backoffMax
+		}
+		c.hold()
+		c.backoffTimer = time.AfterFunc(c.backoffDelay, c.endBackoff)
+	case err == nil:
+		c.backoffDelay = 0
+		if c.backoffTimer != nil && c.backoffTimer.Stop() {
+			c.backoffTimer = nil
+			c.release()
+		}
+	}
+}
+
+// endBackoff attempts the automatic checkpoints again at the end of the delay of WithDiskFullBackoff
+func (c *Checkpointer) endBackoff() {
+	c.lock()
+	defer c.unlock()
+	c.backoffTimer = nil
+	c.release()
+	if due, _ := c.due(0); due && !c.closed {
+		c.pending = true
+		c.g.block()
+	}
+}
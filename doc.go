@@ -0,0 +1,18 @@
+// Package sqlite helps with SQLite databases in WAL mode opened with database/sql
+//
+// Concurrent writes make the WAL file grow without limit because SQLite cannot restart it while
+// it is in use, see https://www.sqlite.org/wal.html#avoiding_excessively_large_wal_files
+// A Checkpointer disables the automatic checkpoints and performs them itself, blocking the
+// writes for the duration of the checkpoint.
+//
+// A Checkpointer is created with NewCheckpointer, for a database opened so that none of its connections
+// checkpoints automatically, for instance by OpenWithCheckpointer, and configured with options:
+//
+//	c, err := sqlite.NewCheckpointer(db,
+//		sqlite.WithLimit(1000),              // checkpoint every 1000 writes
+//		sqlite.WithWALSizeLimit(64<<20),     // or when the WAL file reaches 64 MiB
+//		sqlite.WithInterval(30*time.Second), // or 30s after the last checkpoint
+//		sqlite.WithMode(sqlite.Truncate),
+//		sqlite.WithErrorHandler(func(err error) { log.Println(err) }),
+//	)
+package sqlite
@@ -0,0 +1,152 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DrainAndCheckpoint stops counting the calls to Checkpoint, which then return immediately with a no-op function,
+// waits for the calls in progress and performs a Truncate checkpoint, for instance before a server exits
+// Unlike Close, the Checkpointer is re-armed by Undrain
+// If ctx is done before the checkpoint, the Checkpointer stays drained
+func (c *Checkpointer) DrainAndCheckpoint(ctx context.Context) error {
+	atomic.StoreInt32(&c.drained, 1)
+	_, err := c.force(ctx, Truncate)
+	return err
+}
+
+// WaitForEmptyWAL performs Truncate checkpoints until one of them empties the WAL, waiting between the attempts
+// from 10ms up to 1s for the readers and writers to finish, for instance before copying the database files
+// It returns an error wrapping the error of ctx and the last failure if ctx is done first
+func (c *Checkpointer) WaitForEmptyWAL(ctx context.Context) error {
+	var last error
+	for delay := 10 * time.Millisecond; ; {
+		res, err := c.force(ctx, Truncate)
+		if ctx.Err() == nil {
+			if err == nil && res.WALPages <= 0 {
+				return nil
+			}
+			if err != nil && !isBusy(err) {
+				return err
+			}
+			if last = err; last == nil {
+				last = fmt.Errorf("%d pages left", res.WALPages)
+			}
+			t := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+			case <-t.C:
+			}
+		}
+		if ctx.Err() != nil {
+			if last == nil {
+				return fmt.Errorf("sqlite: WAL not empty: %w", ctx.Err())
+			}
+			return fmt.Errorf("sqlite: WAL not empty: %w (%v)", ctx.Err(), last)
+		}
+		if delay *= 2; delay > time.Second {
+			delay = time.Second
+		}
+	}
+}
+
+// ForceCheckpoint performs checkpoints until one of them writes back the whole WAL, escalating from Passive
+// to Restart then Truncate and waiting between the attempts from 10ms up to 1s, for instance before a critical operation
+// Like Force, each attempt waits for the calls to Checkpoint in progress and resets the counter
+// It returns the mode of the successful checkpoint and the number of attempts, or an error wrapping the error of ctx
+// and the last failure if ctx is done first
+func (c *Checkpointer) ForceCheckpoint(ctx context.Context) (mode CheckpointMode, attempts int, err error) {
+	var last error
+	mode = Passive
+	for delay := 10 * time.Millisecond; ; {
+		res, err := c.force(ctx, mode)
+		if ctx.Err() == nil {
+			attempts++
+			if err == nil && !res.Busy && !res.Partial() {
+				return mode, attempts, nil
+			}
+			if err != nil && !isBusy(err) {
+				return mode, attempts, err
+			}
+			if last = err; last == nil {
+				last = fmt.Errorf("%s checkpoint left %d pages", mode, res.WALPages-max0(res.CheckpointedPages))
+			}
+			if mode == Passive {
+				mode = Restart
+			} else {
+				mode = Truncate
+			}
+			t := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+			case <-t.C:
+			}
+		}
+		if ctx.Err() != nil {
+			if last == nil {
+				return mode, attempts, fmt.Errorf("sqlite: checkpoint not completed: %w", ctx.Err())
+			}
+			return mode, attempts, fmt.Errorf("sqlite: checkpoint not completed after %d attempts: %w (%v)", attempts, ctx.Err(), last)
+		}
+		if delay *= 2; delay > time.Second {
+			delay = time.Second
+		}
+	}
+}
+
+// Pause prevents the automatic checkpoints until Resume is called as many times as Pause,
+// for instance during a bulk import followed by Flush
+// The pauses are counted, so that nested pauses, for instance of IngestMode and of the caller, end with the outer one
+// The calls to Checkpoint are still counted and still wait for the checkpoints performed by Flush or Force
+func (c *Checkpointer) Pause() {
+	c.lock()
+	c.pauses++
+	c.hold()
+	c.unlock()
+}
+
+// Resume undoes a call to Pause, it does nothing if every call to Pause is already undone
+// Once the last Pause is undone, if the limit was reached meanwhile, the next call to Checkpoint performs a checkpoint
+// It does not undo DrainAndCheckpoint, see Undrain
+func (c *Checkpointer) Resume() {
+	c.lock()
+	defer c.unlock()
+	if c.pauses == 0 {
+		return
+	}
+	c.pauses--
+	c.release()
+	if due, _ := c.due(0); due && !c.closed {
+		c.pending = true
+		c.g.block()
+	}
+}
+
+// Undrain counts the calls to Checkpoint again after DrainAndCheckpoint, it does nothing otherwise
+// A single call undoes any number of calls to DrainAndCheckpoint, and it does not undo Pause
+func (c *Checkpointer) Undrain() {
+	atomic.StoreInt32(&c.drained, 0)
+}
+
+// hold holds off the automatic checkpoints until release is called
+func (c *Checkpointer) hold() {
+	atomic.AddInt32(&c.holds, 1)
+}
+
+func (c *Checkpointer) release() {
+	atomic.AddInt32(&c.holds, -1)
+}
+
+// held reports whether the automatic checkpoints are held off
+func (c *Checkpointer) held() bool {
+	return atomic.LoadInt32(&c.holds) > 0
+}
+
+// isDrained reports whether DrainAndCheckpoint was called and Undrain was not called since
+func (c *Checkpointer) isDrained() bool {
+	return atomic.LoadInt32(&c.drained) != 0
+}
@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// writes performs n calls to Checkpoint inserting a row and returns the number of checkpoints they performed
+func writes(t *testing.T, c *Checkpointer, db *sql.DB, n int) uint64 {
+	t.Helper()
+	before := c.Stats().TotalCheckpoints
+	for i := 0; i < n; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	return c.Stats().TotalCheckpoints - before
+}
+
+func TestPauseResume(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(5))
+	c.Resume() // no-op
+	c.Pause()
+	c.Pause()
+	if n := writes(t, c, db, 20); n != 0 {
+		t.Fatalf("%d checkpoints while paused", n)
+	}
+	c.Resume()
+	if n := writes(t, c, db, 20); n != 0 {
+		t.Fatalf("%d checkpoints after one Resume of two Pause", n)
+	}
+	c.Resume()
+	if n := writes(t, c, db, 1); n != 1 {
+		t.Fatalf("%d checkpoints by the first call after the last Resume, want 1", n)
+	}
+	c.Resume() // no-op, the next Pause is not undone in advance
+	c.Pause()
+	if n := writes(t, c, db, 20); n != 0 {
+		t.Fatalf("%d checkpoints while paused after an extra Resume", n)
+	}
+	c.Resume()
+	if n := writes(t, c, db, 10); n == 0 {
+		t.Fatal("no checkpoint after Resume")
+	}
+}
+
+func TestDrainResume(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(5))
+	if err := c.DrainAndCheckpoint(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.Pause()
+	c.Resume()
+	// Resume undoes the Pause, not the drain
+	writes(t, c, db, 3)
+	if n, _ := c.SinceLastCheckpoint(); n != 0 {
+		t.Fatalf("%d calls counted while drained", n)
+	}
+	c.Undrain()
+	writes(t, c, db, 3)
+	if n, _ := c.SinceLastCheckpoint(); n != 3 {
+		t.Fatalf("%d calls counted after Undrain, want 3", n)
+	}
+}
@@ -0,0 +1,12 @@
+package sqlite
+
+// WithDryRun skips the pragmas of the checkpoints and of the maintenance if dryRun is true, everything else
+// happens as usual: the triggers, the counter, the hooks and the statistics, so that a configuration can be
+// evaluated against real traffic without checkpointing, see Stats.DryRunCheckpoints
+// The results of the skipped checkpoints have -1 pages, like for a database that is not in WAL mode
+func WithDryRun(dryRun bool) Option {
+	return func(c *Checkpointer) error {
+		c.dryRun = dryRun
+		return nil
+	}
+}
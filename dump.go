@@ -0,0 +1,226 @@
+package sqlite
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dump writes the main database of db to w as SQL statements, like the .dump command of the sqlite3 shell:
+// the schema and the rows as insert statements, in a transaction so that they are consistent even while
+// other connections write
+// The rows are streamed one by one, their values are quoted by the quote() function of SQLite, so that the
+// blobs are hexadecimal literals such as X'00FF' and the reals are exact
+// The statistics of analyze are not dumped and the virtual tables are not supported
+func Dump(ctx context.Context, db *sql.DB, w io.Writer) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	type object struct{ typ, name, sql string }
+	var objects []object
+	rows, err := tx.QueryContext(ctx, `select type, name, sql from sqlite_master where sql is not null order by type != 'table', rowid`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.typ, &o.name, &o.sql); err != nil {
+			return err
+		}
+		objects = append(objects, o)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, o := range objects {
+		if o.typ == "table" && strings.HasPrefix(strings.ToUpper(o.sql), "CREATE VIRTUAL TABLE") {
+			return fmt.Errorf("sqlite: dumping virtual table %s is not supported", o.name)
+		}
+		switch {
+		case o.name == "sqlite_sequence":
+			// Created along with the first table with autoincrement
+			bw.WriteString(`delete from "sqlite_sequence";` + "\n")
+		case strings.HasPrefix(o.name, "sqlite_"):
+			continue
+		default:
+			bw.WriteString(o.sql + ";\n")
+		}
+		if o.typ == "table" {
+			if err := dumpRows(ctx, tx, bw, o.name); err != nil {
+				return fmt.Errorf("sqlite: dumping %s: %w", o.name, err)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// dumpRows writes the rows of table as insert statements
+func dumpRows(ctx context.Context, tx *sql.Tx, w *bufio.Writer, table string) error {
+	// The generated columns cannot be inserted
+	rows, err := tx.QueryContext(ctx, `select name from pragma_table_xinfo(?) where hidden = 0 order by cid`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var columns, values []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		columns = append(columns, quoteIdent(name))
+		values = append(values, `quote(`+quoteIdent(name)+`)`)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	prefix := `insert into ` + quoteIdent(table) + `(` + strings.Join(columns, ",") + `) values(`
+	rows, err = tx.QueryContext(ctx, `select `+strings.Join(values, ` || ',' || `)+` from `+quoteIdent(table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var row string
+	for rows.Next() {
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		w.WriteString(prefix)
+		w.WriteString(row)
+		if _, err := w.WriteString(");\n"); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Restore executes the SQL statements read from r on db, for instance written by Dump, in a transaction
+// with the foreign keys deferred, so that either all of them or none of them are applied
+// The statements are read one by one, r is not buffered in memory
+func Restore(ctx context.Context, db *sql.DB, r io.Reader) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `pragma defer_foreign_keys = on`); err != nil {
+		return err
+	}
+	s := statements{r: bufio.NewReader(r)}
+	for n := 1; ; n++ {
+		stmt, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("sqlite: restoring statement %d: %w", n, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// statements splits SQL text into statements, at the semicolons outside of the quotes, the comments and
+// the bodies of the triggers
+type statements struct {
+	r *bufio.Reader
+	b strings.Builder
+}
+
+// next returns the next statement, or io.EOF once there is none left
+func (s *statements) next() (string, error) {
+	s.b.Reset()
+	var (
+		word          strings.Builder
+		first         []string // first three tokens, to tell the triggers
+		prev, prev2   string   // last two tokens
+		tokens        int
+		quote         byte
+		line, comment bool
+		star          bool // the last byte of a comment is *
+	)
+	push := func(token string) {
+		if len(first) < 3 {
+			first = append(first, strings.ToLower(token))
+		}
+		prev2, prev = prev, token
+		tokens++
+	}
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			if tokens == 0 {
+				return "", io.EOF
+			}
+			return s.b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		s.b.WriteByte(c)
+		switch {
+		case quote != 0:
+			// A doubled quote is read as two quoted strings in a row
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case line:
+			line = c != '\n'
+			continue
+		case comment:
+			comment = !(star && c == '/')
+			star = c == '*'
+			continue
+		}
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80 {
+			word.WriteByte(c)
+			continue
+		}
+		if word.Len() > 0 {
+			push(word.String())
+			word.Reset()
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+			push(string(c))
+		case '[':
+			quote = ']'
+			push(string(c))
+		case '-', '/':
+			if next, _ := s.r.Peek(1); len(next) == 1 && (c == '-' && next[0] == '-' || c == '/' && next[0] == '*') {
+				s.b.WriteByte(next[0])
+				s.r.ReadByte()
+				line, comment, star = c == '-', c == '/', false
+				continue
+			}
+			push(string(c))
+		case ';':
+			if tokens == 0 {
+				s.b.Reset()
+				continue
+			}
+			// The statements of the body of a trigger end with semicolons too, the trigger ends with ; end;
+			trigger := len(first) >= 2 && first[0] == "create" &&
+				(first[1] == "trigger" || len(first) == 3 && (first[1] == "temp" || first[1] == "temporary") && first[2] == "trigger")
+			if trigger && !(strings.EqualFold(prev, "end") && prev2 == ";") {
+				push(";")
+				continue
+			}
+			return s.b.String(), nil
+		case ' ', '\t', '\n', '\r', '\f':
+		default:
+			push(string(c))
+		}
+	}
+}
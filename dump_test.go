@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// checksum returns the number of rows of table and a checksum of their values in the database db
+func checksum(t *testing.T, db *sql.DB, table string) (rows int, sum string) {
+	t.Helper()
+	if err := db.QueryRow(`select count(*), coalesce(group_concat(quote(a) || quote(b), ','), '') from (select * from `+quoteIdent(table)+` order by rowid)`).Scan(&rows, &sum); err != nil {
+		t.Fatal(err)
+	}
+	return rows, sum
+}
+
+func TestDumpRestore(t *testing.T) {
+	ctx := context.Background()
+	src := openTest(t, "src.db")
+	if _, err := src.Exec(`
+		create table "odd ""name""" (a, b);
+		create table items (a integer primary key, b);
+		create index items_b on items (b);
+		create view v as select a from items;
+		insert into "odd ""name""" values (null, 'it''s'), (1.5, x'00ff'), (-7, 'line
+break'), ('', 1e300);
+	`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := src.Exec(`insert into items (b) values (randomblob(?))`, i%50); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var dump bytes.Buffer
+	if err := Dump(ctx, src, &dump); err != nil {
+		t.Fatal(err)
+	}
+	dst := openTest(t, "dst.db")
+	if _, err := dst.Exec(`drop table t`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(ctx, dst, bytes.NewReader(dump.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{`odd "name"`, "items"} {
+		wantRows, wantSum := checksum(t, src, table)
+		rows, sum := checksum(t, dst, table)
+		if rows != wantRows || sum != wantSum {
+			t.Errorf("%s: %d rows, want %d, checksums differ: %v", table, rows, wantRows, sum != wantSum)
+		}
+	}
+	var objects int
+	if err := dst.QueryRow(`select count(*) from sqlite_master where name in ('items_b', 'v')`).Scan(&objects); err != nil {
+		t.Fatal(err)
+	}
+	if objects != 2 {
+		t.Errorf("%d of the index and the view restored, want 2", objects)
+	}
+}
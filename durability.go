@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithSynchronous sets pragma synchronous to level (off, normal, full or extra) like WithBusyTimeout sets busy_timeout:
+// on a connection of the pool during NewCheckpointer and on the connection performing each checkpoint,
+// use WithPragma of Open for every connection of the pool
+// With synchronous = normal in WAL mode, the commits are not synced, they are durable once a checkpoint
+// wrote them back to the database, which the checkpoint syncs, see WithOnDurable
+func WithSynchronous(level string) Option {
+	return func(c *Checkpointer) error {
+		switch l := strings.ToLower(level); l {
+		case "off", "normal", "full", "extra":
+			c.synchronous = l
+			return nil
+		}
+		return fmt.Errorf("sqlite: invalid synchronous level %q", level)
+	}
+}
+
+// WithOnDurable sets a function called after every checkpoint that wrote back the whole WAL, such as any
+// successful Truncate checkpoint, with the time it started: the transactions committed before are durable
+// even with synchronous = normal, unless the checkpoint ran with synchronous = off
+// Like the function set by WithOnCheckpoint, it is called after the lock is released and writers are unblocked
+func WithOnDurable(f func(at time.Time)) Option {
+	return func(c *Checkpointer) error {
+		c.onDurable = f
+		return nil
+	}
+}
+
+// WithJournalSizeLimit sets pragma journal_size_limit like WithBusyTimeout sets busy_timeout, so that the WAL
+// file is truncated to at most size bytes when it is reset after a checkpoint instead of keeping its largest size
+// The WAL is reset by the next write following a Restart checkpoint, on the connection of the write:
+// use WithPragma of Open to set it on every connection of the pool
+// NewCheckpointer fails if the pragma does not read back as size
+func WithJournalSizeLimit(size int64) Option {
+	return func(c *Checkpointer) error {
+		if size < 0 {
+			return fmt.Errorf("sqlite: invalid journal size limit %d", size)
+		}
+		c.journalSizeLimit = size
+		return nil
+	}
+}
+
+func (c *Checkpointer) journalSizeLimitPragma() string {
+	return schemaPragma(c.schema, "journal_size_limit")
+}
+
+// checkJournalSizeLimit sets the pragma of WithJournalSizeLimit and checks the value it returns
+func (c *Checkpointer) checkJournalSizeLimit(db queryer) error {
+	var got int64
+	if err := db.QueryRowContext(context.Background(), c.journalSizeLimitPragma()+` = `+strconv.FormatInt(c.journalSizeLimit, 10)).Scan(&got); err != nil {
+		return fmt.Errorf("sqlite: checking pragma journal_size_limit: %w", err)
+	}
+	if got != c.journalSizeLimit {
+		return fmt.Errorf("sqlite: pragma journal_size_limit is %d instead of %d", got, c.journalSizeLimit)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestJournalSizeLimit(t *testing.T) {
+	const limit = 64 << 10
+	db := openTest(t, "test.db")
+	// The pragma is a setting of the connection, the one of the Checkpointer resets the WAL
+	db.SetMaxOpenConns(1)
+	c := newTest(t, db, WithLimit(1<<20), WithMode(Restart), WithJournalSizeLimit(limit))
+	var got int64
+	if err := db.QueryRow(`pragma journal_size_limit`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != limit {
+		t.Fatalf("journal_size_limit = %d, want %d", got, limit)
+	}
+	for i := 0; i < 500; i++ {
+		done := c.Checkpoint()
+		if _, err := db.Exec(`insert into t values (randomblob(1000))`); err != nil {
+			t.Fatal(err)
+		}
+		done()
+	}
+	_, before, _, err := c.Sizes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before <= limit {
+		t.Fatalf("WAL of %d bytes, want more than %d", before, limit)
+	}
+	if err := c.Force(Restart); err != nil {
+		t.Fatal(err)
+	}
+	// The WAL is reset by the next write
+	insert(t, db)
+	_, after, _, err := c.Sizes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after > limit {
+		t.Errorf("WAL of %d bytes after the Restart checkpoint, want at most %d", after, limit)
+	}
+}
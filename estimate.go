@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// estimateCalls is the maximum number of calls of the sample function of EstimateLimit
+const estimateCalls = 1000
+
+// EstimateLimit suggests a limit, see WithLimit and SetLimit, so that the WAL grows to about targetWALBytes
+// between the checkpoints, by measuring the growth of the WAL files caused by sampleWrites
+// sampleWrites must perform one typical operation on the live database, for instance with Exec or WithTx,
+// it is called up to 1000 times, until the WAL files reach targetWALBytes
+// EstimateLimit starts and ends with a Truncate checkpoint, the automatic checkpoints are held off
+// meanwhile like with Pause, it returns ErrReadOnly if a database cannot be written to
+func (c *Checkpointer) EstimateLimit(sampleWrites func() error, targetWALBytes int64) (uint, error) {
+	if targetWALBytes <= 0 {
+		return 0, fmt.Errorf("sqlite: invalid target WAL size %d", targetWALBytes)
+	}
+	for _, d := range c.dbs {
+		if err := checkWritable(d.q); err != nil {
+			return 0, err
+		}
+	}
+	ctx := context.Background()
+	if _, err := c.force(ctx, Truncate); err != nil {
+		return 0, fmt.Errorf("sqlite: emptying the WAL before the estimation: %w", err)
+	}
+	c.Pause()
+	calls, wal, err := sample(ctx, c, sampleWrites, targetWALBytes)
+	c.Resume()
+	if _, err2 := c.force(ctx, Truncate); err == nil && err2 != nil {
+		err = fmt.Errorf("sqlite: emptying the WAL after the estimation: %w", err2)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if wal == 0 {
+		return 0, errors.New("sqlite: the sample writes did not grow the WAL")
+	}
+	limit := uint(float64(targetWALBytes) / (float64(wal) / float64(calls)))
+	if limit == 0 {
+		limit = 1
+	}
+	return limit, nil
+}
+
+// sample calls fn until the WAL files reach target bytes and returns the number of calls and their size
+func sample(ctx context.Context, c *Checkpointer, fn func() error, target int64) (calls int, wal int64, err error) {
+	for calls < estimateCalls && wal < target {
+		if err := fn(); err != nil {
+			return calls, wal, err
+		}
+		calls++
+		if _, wal, _, err = c.Sizes(ctx); err != nil {
+			return calls, wal, err
+		}
+	}
+	return calls, wal, nil
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Exec executes a query between Checkpoint and the call of the function it returns, see ExecContext
+func (c *Checkpointer) Exec(query string, args ...any) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query on the database between CheckpointContext and the call of the function it returns,
+// so that the operation is counted and a checkpoint never runs concurrently
+// With NewMultiCheckpointer, the query is executed on the first database
+// Use WithTx for transactions
+func (c *Checkpointer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	done, err := c.CheckpointContext(ctx)
+	if err != nil && err != ErrClosed {
+		return nil, err
+	}
+	defer done()
+	return c.dbs[0].q.ExecContext(ctx, query, args...)
+}
+
+// WithTx runs fn in a transaction on the database between CheckpointContext and the call of the function it returns,
+// so that the transaction counts as one operation and a checkpoint never runs while it is open
+// The transaction is committed if fn returns nil and rolled back otherwise or if fn panics
+// The transaction is immediate: the database must be opened by OpenWithCheckpointer, or by Open with
+// WithTxLock("immediate"), WithTx returns ErrDeferredTx otherwise, as with a connection of NewCheckpointerConn
+// Use Savepoint for the nested units of work that can fail without failing the transaction
+// With NewMultiCheckpointer, the transaction is opened on the first database
+func (c *Checkpointer) WithTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	if !immediate(c.dbs[0].q) {
+		return ErrDeferredTx
+	}
+	done, err := c.CheckpointContext(ctx)
+	if err != nil && err != ErrClosed {
+		return err
+	}
+	defer done()
+	return runTx(ctx, c.dbs[0].q, fn)
+}
+
+// Batch loads rows in chunks: it calls fn with the indexes 0, 1, 2... until it returns done or an error,
+// in a transaction committed every chunkSize calls, each transaction counting as one operation like with WithTx
+// so that the checkpoints keep the WAL bounded during the load
+// It stops on the first error, rolling back the transaction of the failing chunk, and between the chunks when ctx is done
+func (c *Checkpointer) Batch(ctx context.Context, chunkSize int, fn func(tx *sql.Tx, i int) (done bool, err error)) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("sqlite: invalid chunk size %d", chunkSize)
+	}
+	for i, finished := 0, false; !finished; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.WithTx(ctx, func(tx *sql.Tx) error {
+			for end := i + chunkSize; i < end && !finished; i++ {
+				var err error
+				if finished, err = fn(tx, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DB wraps the database of a Checkpointer so that its writes are counted without calling Checkpoint, see Checkpointer.DB
+type DB struct {
+	c *Checkpointer
+}
+
+// DB returns a wrapper of the database (the first one with NewMultiCheckpointer) executing the writes
+// between Checkpoint and the call of the function it returns
+func (c *Checkpointer) DB() *DB {
+	return &DB{c}
+}
+
+// Checkpointer returns the Checkpointer of the wrapper
+func (db *DB) Checkpointer() *Checkpointer {
+	return db.c
+}
+
+// Unwrap returns the underlying database, whose operations are not counted, nil with NewCheckpointerConn
+func (db *DB) Unwrap() *sql.DB {
+	return db.c.dbs[0].db
+}
+
+// Exec is like Checkpointer.Exec
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.c.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext is like Checkpointer.ExecContext
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.c.ExecContext(ctx, query, args...)
+}
+
+// Query is like sql.DB.Query, see QueryContext
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryContext is like sql.DB.QueryContext, it is not counted: the rows are read after it returns,
+// holding a read transaction that no guard can cover, and reads do not grow the WAL anyway
+// Use WithTx for the writes returning rows
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.c.dbs[0].q.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext is like sql.DB.QueryRowContext, it is not counted, see QueryContext
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.c.dbs[0].q.QueryRowContext(ctx, query, args...)
+}
+
+// WithTx is like Checkpointer.WithTx
+func (db *DB) WithTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	return db.c.WithTx(ctx, fn)
+}
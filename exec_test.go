@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWithTxImmediate(t *testing.T) {
+	// Immediate by default
+	db, c, err := OpenWithCheckpointer(filepath.Join(t.TempDir(), "test.db"), []Option{WithLimit(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	defer c.Close()
+	if _, err := db.Exec(`create table t (v)`); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := c.WithTx(context.Background(), func(tx *sql.Tx) error {
+					var n int
+					if err := tx.QueryRow(`select count(*) from t`).Scan(&n); err != nil {
+						return err
+					}
+					_, err := tx.Exec(`insert into t values (?)`, n)
+					return err
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	var n int
+	if err := db.QueryRow(`select count(distinct v) from t`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 160 {
+		t.Errorf("%d distinct rows, want 160", n)
+	}
+	if s := c.Stats(); s.TotalCheckpoints == 0 {
+		t.Error("no checkpoint")
+	}
+}
+
+func TestWithTxDeferred(t *testing.T) {
+	db := openTest(t, "test.db", WithTxLock("deferred"))
+	c := newTest(t, db, WithLimit(10))
+	if err := c.WithTx(context.Background(), func(*sql.Tx) error { return nil }); !errors.Is(err, ErrDeferredTx) {
+		t.Errorf("WithTx returned %v", err)
+	}
+	if n := c.PendingWriters(); n != 0 {
+		t.Errorf("PendingWriters = %d", n)
+	}
+}
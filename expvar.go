@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// PublishExpvar publishes the statistics as an expvar.Map named name, updated after each checkpoint:
+// checkpoints, failures, busy, last_duration_ms and wal_pages
+// If a map with this name is already published, for instance by a previous Checkpointer, it is reused
+func (c *Checkpointer) PublishExpvar(name string) error {
+	var m *expvar.Map
+	switch v := expvar.Get(name).(type) {
+	case nil:
+		m = expvar.NewMap(name)
+	case *expvar.Map:
+		m = v
+	default:
+		return fmt.Errorf("sqlite: expvar %q is already published as a %T", name, v)
+	}
+	c.lock()
+	defer c.unlock()
+	c.expvar = m
+	c.publish()
+	return nil
+}
+
+// publish updates the published statistics, the caller must hold the lock
+func (c *Checkpointer) publish() {
+	if c.expvar == nil {
+		return
+	}
+	set := func(key string, v int64) {
+		i := new(expvar.Int)
+		i.Set(v)
+		c.expvar.Set(key, i)
+	}
+	set("checkpoints", int64(c.stats.TotalCheckpoints))
+	set("failures", int64(c.stats.FailedCheckpoints))
+	set("busy", int64(c.stats.BusyCheckpoints))
+	set("last_duration_ms", c.stats.LastDuration.Milliseconds())
+	set("wal_pages", int64(c.stats.WALPages))
+}
@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package sqlite
+
+import (
+	"errors"
+	"os"
+)
+
+const processLockSupported = false
+
+var errNoProcessLock = errors.New("sqlite: process locks are not supported")
+
+func tryLockFile(*os.File) (bool, error) {
+	return false, errNoProcessLock
+}
+
+func unlockFile(*os.File) error {
+	return errNoProcessLock
+}
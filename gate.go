@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// gate counts the calls in progress and lets a checkpoint exclude them
+// Entering and leaving only use atomic operations, so that calls do not contend on a mutex
+// while no checkpoint is due
+type gate struct {
+	n       int64         // number of calls in progress, first for 64-bit alignment
+	blocked int32         // nonzero while callers must take the locked path
+	idle    chan struct{} // signaled when n reaches zero while blocked
+}
+
+func newGate() gate {
+	return gate{idle: make(chan struct{}, 1)}
+}
+
+// enter registers a call if the gate is not blocked
+func (g *gate) enter() bool {
+	if atomic.LoadInt32(&g.blocked) != 0 {
+		return false
+	}
+	atomic.AddInt64(&g.n, 1)
+	// A checkpoint may have blocked the gate before seeing this call
+	if atomic.LoadInt32(&g.blocked) != 0 {
+		g.leave()
+		return false
+	}
+	return true
+}
+
+// add registers a call even if the gate is blocked, the caller must hold the lock of the Checkpointer
+func (g *gate) add() {
+	atomic.AddInt64(&g.n, 1)
+}
+
+func (g *gate) leave() {
+	n := atomic.AddInt64(&g.n, -1)
+	if n < 0 {
+		panic("sqlite: negative count of calls in progress")
+	}
+	if n == 0 && atomic.LoadInt32(&g.blocked) != 0 {
+		select {
+		case g.idle <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (g *gate) count() int {
+	return int(atomic.LoadInt64(&g.n))
+}
+
+func (g *gate) block() {
+	atomic.StoreInt32(&g.blocked, 1)
+}
+
+func (g *gate) isBlocked() bool {
+	return atomic.LoadInt32(&g.blocked) != 0
+}
+
+func (g *gate) unblock() {
+	atomic.StoreInt32(&g.blocked, 0)
+}
+
+// wait blocks until there are no calls in progress or ctx is done, the gate must be blocked
+func (g *gate) wait(ctx context.Context) error {
+	for atomic.LoadInt64(&g.n) > 0 {
+		select {
+		case <-g.idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrGuardTimeout is reported when an automatic checkpoint is skipped because calls to Checkpoint
+// were still in progress after the guard timeout, typically because the function it returned was not called
+var ErrGuardTimeout = errors.New("sqlite: calls to Checkpoint still in progress")
+
+// ErrSlowGuard is reported with WithMaxGuardDuration when a checkpoint waits for calls to Checkpoint
+// held for too long
+var ErrSlowGuard = errors.New("sqlite: calls to Checkpoint held too long")
+
+// defaultGuardTimeout is the guard timeout if WithGuardTimeout is not used
+const defaultGuardTimeout = 5 * time.Second
+
+// WithGuardTimeout sets how long an automatic checkpoint waits for the calls to Checkpoint in progress,
+// the default is 5s and 0 waits forever
+// Once it elapses, the checkpoint is skipped, ErrGuardTimeout is reported and the counter is reset
+// so that the writers are not blocked by a call whose function was never invoked
+// Flush, Force, CheckpointNow and Close are not affected
+func WithGuardTimeout(d time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if d < 0 {
+			return fmt.Errorf("sqlite: invalid guard timeout %v", d)
+		}
+		c.guardTimeout = d
+		return nil
+	}
+}
+
+// WithGuardStacks records the stack trace of each call to Checkpoint until the function it returned is called,
+// so that ErrGuardTimeout and ErrSlowGuard are reported with the stack traces of the calls in progress
+// It slows down every call, it is intended for debugging
+func WithGuardStacks() Option {
+	return func(c *Checkpointer) error {
+		if c.guards == nil {
+			c.guards = newGuards()
+		}
+		c.guards.stacks = true
+		return nil
+	}
+}
+
+// WithMaxGuardDuration reports ErrSlowGuard when a checkpoint waits for calls to Checkpoint held for more than d,
+// with the longest duration, so that a stuck writer delaying the checkpoints is noticed, see WithGuardStacks
+// The calls are not released, the checkpoint still waits for them, see WithGuardTimeout
+// It is reported like the failures of the checkpoints, after the checkpoint
+func WithMaxGuardDuration(d time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if d <= 0 {
+			return fmt.Errorf("sqlite: invalid max guard duration %v", d)
+		}
+		if c.guards == nil {
+			c.guards = newGuards()
+		}
+		c.maxGuard = d
+		return nil
+	}
+}
+
+// guards are the calls in progress with their start and, with WithGuardStacks, their stack traces
+type guards struct {
+	mu     sync.Mutex
+	id     uint64
+	calls  map[uint64]guard
+	stacks bool // see WithGuardStacks
+}
+
+type guard struct {
+	start time.Time
+	stack []byte
+}
+
+func newGuards() *guards {
+	return &guards{calls: make(map[uint64]guard)}
+}
+
+// track records the call of the caller and returns the function forgetting it
+func (g *guards) track() func() {
+	call := guard{start: time.Now()}
+	if g.stacks {
+		call.stack = make([]byte, 4<<10)
+		call.stack = call.stack[:runtime.Stack(call.stack, false)]
+	}
+	g.mu.Lock()
+	g.id++
+	id := g.id
+	g.calls[id] = call
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		delete(g.calls, id)
+		g.mu.Unlock()
+	}
+}
+
+// String returns the stack traces of the calls in progress
+func (g *guards) String() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var b strings.Builder
+	for _, call := range g.calls {
+		b.WriteString("\n\n")
+		b.Write(call.stack)
+	}
+	return b.String()
+}
+
+// slow returns ErrSlowGuard if calls in progress are held for more than d, nil otherwise
+func (g *guards) slow(d time.Duration) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var n int
+	var longest time.Duration
+	var b strings.Builder
+	for _, call := range g.calls {
+		if held := time.Since(call.start); held > d {
+			n++
+			if held > longest {
+				longest = held
+			}
+			if g.stacks {
+				b.WriteString("\n\n")
+				b.Write(call.stack)
+			}
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%w: %d for more than %v, the longest for %v", ErrSlowGuard, n, d, longest.Round(time.Millisecond))
+	if g.stacks {
+		err = fmt.Errorf("%w, started at:%s", err, b.String())
+	}
+	return err
+}
+
+// waitGuards is the wait of exclude with WithMaxGuardDuration: it records ErrSlowGuard if calls in progress
+// were held for more than the max guard duration before the checkpoint, or once it has waited for as long
+func (c *Checkpointer) waitGuards(ctx context.Context) error {
+	if c.slowGuards = c.guards.slow(c.maxGuard); c.slowGuards == nil {
+		tctx, cancel := context.WithTimeout(ctx, c.maxGuard)
+		err := c.g.wait(tctx)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		c.slowGuards = c.guards.slow(c.maxGuard)
+	}
+	return c.g.wait(ctx)
+}
+
+// excludeAuto is exclude for the automatic checkpoints, it gives up with ErrGuardTimeout after the guard timeout
+func (c *Checkpointer) excludeAuto(ctx context.Context) error {
+	if c.guardTimeout == 0 {
+		return c.exclude(ctx)
+	}
+	tctx, cancel := context.WithTimeout(ctx, c.guardTimeout)
+	defer cancel()
+	err := c.exclude(tctx)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	err = fmt.Errorf("%w: %d after %v", ErrGuardTimeout, c.g.count(), c.guardTimeout)
+	if c.guards != nil && c.guards.stacks {
+		err = fmt.Errorf("%w, started at:%s", err, c.guards)
+	}
+	return err
+}
+
+// skip gives up a checkpoint after ErrGuardTimeout, the caller must hold the lock
+func (c *Checkpointer) skip() {
+	// ErrGuardTimeout reports the calls in progress already
+	c.slowGuards = nil
+	c.reset()
+	c.settle()
+}
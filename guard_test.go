@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorsOf returns an option collecting the errors reported to WithErrorHandler and a function returning them
+func errorsOf() (Option, func() []error) {
+	var mu sync.Mutex
+	var errs []error
+	return WithErrorHandler(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}), func() []error {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]error(nil), errs...)
+		}
+}
+
+func TestLeakedGuard(t *testing.T) {
+	db := openTest(t, "test.db")
+	onError, reported := errorsOf()
+	c := newTest(t, db, WithLimit(2), WithGuardTimeout(50*time.Millisecond), WithGuardStacks(), onError)
+	// Released at the end only, Close waits for it
+	leaked := c.Checkpoint()
+	defer leaked()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > 5*time.Second {
+		t.Errorf("the calls took %v, want about the guard timeout", d)
+	}
+	errs := reported()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrGuardTimeout) {
+		t.Fatalf("reported %v, want ErrGuardTimeout", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "TestLeakedGuard") {
+		t.Errorf("the error does not have the stack trace of the leaked call: %v", errs[0])
+	}
+	if n := c.Stats().TotalCheckpoints; n != 0 {
+		t.Errorf("TotalCheckpoints = %d, want 0", n)
+	}
+}
+
+func TestMaxGuardDuration(t *testing.T) {
+	db := openTest(t, "test.db")
+	onError, reported := errorsOf()
+	c := newTest(t, db, WithLimit(2), WithMaxGuardDuration(20*time.Millisecond), WithGuardStacks(), onError)
+	started := make(chan struct{})
+	slow := make(chan struct{})
+	go func() {
+		defer close(slow)
+		done := c.Checkpoint()
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		done()
+	}()
+	<-started
+	// The third call performs the checkpoint, which waits for the slow one
+	for i := 0; i < 2; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	<-slow
+	errs := reported()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrSlowGuard) {
+		t.Fatalf("reported %v, want ErrSlowGuard", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "TestMaxGuardDuration") {
+		t.Errorf("the error does not have the stack trace of the slow call: %v", errs[0])
+	}
+	if n := c.Stats().TotalCheckpoints; n != 1 {
+		t.Errorf("TotalCheckpoints = %d, want 1, the slow call is not released", n)
+	}
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStuck is reported once the checkpoints failed to write back the whole WAL WithStuckAfter times in a row,
+// typically because a forgotten *sql.Rows or a long read transaction pins the WAL
+var ErrStuck = errors.New("sqlite: checkpoints stuck, a reader may be pinning the WAL")
+
+// ErrWALPinned is reported once pinnedAfter checkpoints in a row wrote back no page of a non-empty WAL,
+// a read transaction keeping every page of the WAL in use
+var ErrWALPinned = errors.New("sqlite: WAL pinned by a reader, the checkpoints write back no page")
+
+// pinnedAfter is the number of checkpoints in a row without progress after which ErrWALPinned is reported
+const pinnedAfter = 3
+
+// defaultStuckAfter is the number of incomplete checkpoints in a row after which the health is Stuck
+const defaultStuckAfter = 10
+
+// WithStuckAfter sets the number of busy or partial checkpoints in a row after which the health is Stuck
+// and ErrStuck is reported, the default is 10
+func WithStuckAfter(n int) Option {
+	return func(c *Checkpointer) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid number of incomplete checkpoints %d", n)
+		}
+		c.stuckAfter = n
+		return nil
+	}
+}
+
+// HealthThresholds are the limits beyond which IsHealthy reports the Checkpointer as unhealthy,
+// the zero fields keep their defaults
+type HealthThresholds struct {
+	Failures   int           // number of failed checkpoints in a row, not counting the busy ones, the default is 3
+	Incomplete time.Duration // time the checkpoints have been busy or partial, since the last one that wrote back the whole WAL, the default is 10 minutes
+}
+
+// defaultHealthThresholds are the defaults of WithHealthThresholds
+var defaultHealthThresholds = HealthThresholds{Failures: 3, Incomplete: 10 * time.Minute}
+
+// WithHealthThresholds sets the thresholds of IsHealthy
+func WithHealthThresholds(t HealthThresholds) Option {
+	return func(c *Checkpointer) error {
+		if t.Failures < 0 || t.Incomplete < 0 {
+			return fmt.Errorf("sqlite: invalid health thresholds (%d failures, %v incomplete)", t.Failures, t.Incomplete)
+		}
+		if t.Failures > 0 {
+			c.thresholds.Failures = t.Failures
+		}
+		if t.Incomplete > 0 {
+			c.thresholds.Incomplete = t.Incomplete
+		}
+		return nil
+	}
+}
+
+// WithReadDB sets the database whose connections in use are reported by Health, for instance the read
+// database of a Pool
+func WithReadDB(db *sql.DB) Option {
+	return func(c *Checkpointer) error {
+		if db == nil {
+			return ErrNilDB
+		}
+		c.readDB = db
+		return nil
+	}
+}
+
+// HealthStatus summarizes the ability of the checkpoints to write back the whole WAL
+type HealthStatus int
+
+const (
+	Healthy  HealthStatus = iota // the last checkpoint wrote back the whole WAL
+	Degraded                     // the last checkpoints were busy or partial
+	Stuck                        // the checkpoints were busy or partial WithStuckAfter times in a row
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Stuck:
+		return "stuck"
+	}
+	return fmt.Sprintf("HealthStatus(%d)", int(s))
+}
+
+// Health is the outcome of the last checkpoints, see Checkpointer.Health
+type Health struct {
+	Status        HealthStatus
+	SinceComplete time.Duration // time since the start of the last checkpoint that wrote back the whole WAL, or since NewCheckpointer
+	Incomplete    int           // number of busy or partial checkpoints in a row
+	Readers       int           // number of connections in use of the database set by WithReadDB, -1 without it
+}
+
+// Health reports whether the checkpoints write back the whole WAL
+func (c *Checkpointer) Health() Health {
+	c.lock()
+	h := Health{SinceComplete: c.since(c.completeAt), Incomplete: c.incomplete, Readers: -1}
+	c.unlock()
+	switch {
+	case h.Incomplete >= c.stuckAfter:
+		h.Status = Stuck
+	case h.Incomplete > 0:
+		h.Status = Degraded
+	}
+	if c.readDB != nil {
+		h.Readers = c.readDB.Stats().InUse
+	}
+	return h
+}
+
+// IsHealthy reports whether the Checkpointer works, and the reason if it does not, for instance for a liveness probe:
+// it does not if the database is closed, if the Checkpointer is closed, if the checkpoints are held off by
+// WithDiskFullBackoff, or beyond the thresholds of WithHealthThresholds, if they failed too many times in a row
+// or have been busy or partial for too long
+func (c *Checkpointer) IsHealthy() (bool, string) {
+	c.lock()
+	defer c.unlock()
+	switch {
+	case errors.Is(c.lastErr, ErrDBClosed):
+		return false, "database closed"
+	case c.closed:
+		return false, "checkpointer closed"
+	case c.backoffTimer != nil:
+		return false, fmt.Sprintf("checkpoints held off, the disk is full: %v", c.lastErr)
+	case c.failedInRow >= c.thresholds.Failures:
+		return false, fmt.Sprintf("%d checkpoints failed in a row: %v", c.failedInRow, c.lastErr)
+	}
+	if since := c.since(c.completeAt); c.incomplete > 0 && since >= c.thresholds.Incomplete {
+		if c.stats.NoProgressCheckpoints >= pinnedAfter {
+			return false, fmt.Sprintf("WAL pinned by a reader, no page written back for %v", since.Round(time.Second))
+		}
+		return false, fmt.Sprintf("checkpoints busy or partial for %v", since.Round(time.Second))
+	}
+	return true, ""
+}
+
+// observe updates the health after a checkpoint started at start, the caller must hold the lock
+func (c *Checkpointer) observe(start time.Time, res CheckpointResult, err error) {
+	if err != nil && !isBusy(err) {
+		c.failedInRow++
+	} else {
+		c.failedInRow = 0
+	}
+	switch {
+	case err != nil && !isBusy(err):
+	case res.Busy || res.Partial():
+		if c.incomplete++; c.incomplete == c.stuckAfter {
+			atomic.StoreInt32(&c.stuck, 1)
+		}
+	default:
+		c.completeAt, c.incomplete = start, 0
+	}
+	if err != nil && !isBusy(err) {
+		return
+	}
+	c.observeReclaim(res)
+	// Without a reset of the WAL, the checkpointed pages only grow
+	if res.WALPages > 0 && res.CheckpointedPages < res.WALPages && res.WALPages >= c.prevPages && res.CheckpointedPages <= c.prevCheckpointed {
+		if c.stats.NoProgressCheckpoints++; c.stats.NoProgressCheckpoints == pinnedAfter {
+			atomic.StoreInt32(&c.pinned, 1)
+		}
+	} else {
+		c.stats.NoProgressCheckpoints = 0
+	}
+	c.prevPages, c.prevCheckpointed = res.WALPages, max0(res.CheckpointedPages)
+}
+
+// reportHealth reports ErrStuck once the health became Stuck and ErrWALPinned once the WAL is pinned,
+// the caller must not hold the lock
+func (c *Checkpointer) reportHealth() {
+	if atomic.CompareAndSwapInt32(&c.stuck, 1, 0) {
+		c.report(CheckpointResult{}, ErrStuck)
+	}
+	if atomic.CompareAndSwapInt32(&c.pinned, 1, 0) {
+		c.report(CheckpointResult{}, ErrWALPinned)
+	}
+}
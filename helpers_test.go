@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// openTest opens a database in WAL mode in the temporary directory of t, with a table t(v),
+// configured like OpenWithCheckpointer
+func openTest(t testing.TB, name string, opts ...OpenOption) *sql.DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), name), append([]OpenOption{withCheckpointer(), WithTxLock("immediate")}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`create table if not exists t (v)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTest returns a Checkpointer of db closed at the end of t
+func newTest(t testing.TB, db *sql.DB, opts ...Option) *Checkpointer {
+	t.Helper()
+	c, err := NewCheckpointer(db, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// insert inserts a row in t
+func insert(t testing.TB, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec(`insert into t values (randomblob(100))`); err != nil {
+		t.Fatal(err)
+	}
+}
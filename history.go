@@ -0,0 +1,39 @@
+package sqlite
+
+import "fmt"
+
+// WithHistory keeps the events of the last n checkpoints, see History
+// They are stored in a buffer allocated once, the oldest event being overwritten by the newest one
+func WithHistory(n int) Option {
+	return func(c *Checkpointer) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid history size %d", n)
+		}
+		c.history = make([]CheckpointEvent, n)
+		return nil
+	}
+}
+
+// History returns a copy of the events of the last checkpoints kept by WithHistory, the newest first,
+// whatever triggered them, nil without WithHistory
+func (c *Checkpointer) History() []CheckpointEvent {
+	c.lock()
+	defer c.unlock()
+	events := make([]CheckpointEvent, 0, c.historyLen)
+	for i := 1; i <= c.historyLen; i++ {
+		events = append(events, c.history[(c.historyNext-i+len(c.history))%len(c.history)])
+	}
+	return events
+}
+
+// remember adds the event of a checkpoint to the history of WithHistory, the caller must hold the lock
+func (c *Checkpointer) remember(res CheckpointResult) {
+	if c.history == nil || res.Mode == 0 {
+		return
+	}
+	c.history[c.historyNext] = CheckpointEvent{Time: c.doneAt, Mode: res.Mode, Result: res, Err: res.Err}
+	c.historyNext = (c.historyNext + 1) % len(c.history)
+	if c.historyLen < len(c.history) {
+		c.historyLen++
+	}
+}
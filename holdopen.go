@@ -0,0 +1,54 @@
+package sqlite
+
+import (
+	"context"
+)
+
+// WithHoldOpen keeps a connection of each database open until Close, unused except to keep the database open,
+// so that SQLite does not delete and recreate the WAL and shared memory files whenever the last connection
+// of the pool is closed between the bursts of writes
+// The connection is checked before each checkpoint and replaced if the driver dropped it,
+// Stats.HoldOpenHealthy reports whether it is open
+// The pool then has one connection less for the other operations
+func WithHoldOpen() Option {
+	return func(c *Checkpointer) error {
+		c.holdOpen = true
+		return nil
+	}
+}
+
+// pin opens the connection of WithHoldOpen of d, reading the schema so that SQLite opens the WAL
+func pin(ctx context.Context, d *database) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	var tables int
+	if err := conn.QueryRowContext(ctx, `select count(*) from sqlite_master`).Scan(&tables); err != nil {
+		conn.Close()
+		return err
+	}
+	d.hold = conn
+	return nil
+}
+
+// keepOpen replaces the connections of WithHoldOpen that were dropped, the caller must hold the lock
+func (c *Checkpointer) keepOpen(ctx context.Context) {
+	if !c.holdOpen {
+		return
+	}
+	healthy := true
+	for _, d := range c.dbs {
+		if d.db == nil || (d.hold != nil && d.hold.PingContext(ctx) == nil) {
+			continue
+		}
+		if d.hold != nil {
+			d.hold.Close()
+			d.hold = nil
+		}
+		if pin(ctx, d) != nil {
+			healthy = false
+		}
+	}
+	c.stats.HoldOpenHealthy = healthy
+}
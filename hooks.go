@@ -0,0 +1,65 @@
+package sqlite
+
+// WithOnCheckpoint sets a function called with the result of every checkpoint, whether it succeeded or not
+// It is called by the goroutine that performed the checkpoint, after the lock is released and writers are unblocked
+// If it panics while called from Checkpoint, the call is released before the panic propagates
+func WithOnCheckpoint(f func(CheckpointResult)) Option {
+	return func(c *Checkpointer) error {
+		c.onCheckpoint = f
+		return nil
+	}
+}
+
+// WithBeforeCheckpoint sets a function called right before every checkpoint
+// Writers are blocked while it runs, so it must be quick and must not use the Checkpointer
+func WithBeforeCheckpoint(f func()) Option {
+	return func(c *Checkpointer) error {
+		c.beforeCheckpoint = f
+		return nil
+	}
+}
+
+// WithAfterCheckpoint sets a function called after every checkpoint with its result and error, even if it failed
+// Like the function set by WithOnCheckpoint, it is called after the lock is released and writers are unblocked
+func WithAfterCheckpoint(f func(CheckpointResult, error)) Option {
+	return func(c *Checkpointer) error {
+		c.afterCheckpoint = f
+		return nil
+	}
+}
+
+// notify calls the functions set by WithOnCheckpoint, WithAfterCheckpoint and WithOnDurable and sends the event
+// of Subscribe if a checkpoint was performed, after reporting the failure of the maintenance that followed it
+// and the health errors
+// The caller must not hold the lock
+func (c *Checkpointer) notify(res CheckpointResult) {
+	if res.Mode == 0 {
+		return
+	}
+	if res.slowGuards != nil {
+		c.report(CheckpointResult{}, res.slowGuards)
+	}
+	if res.maintenance != nil {
+		c.report(CheckpointResult{}, res.maintenance)
+	}
+	c.reportHealth()
+	if res.lowReclaim != nil {
+		c.report(CheckpointResult{}, res.lowReclaim)
+	}
+	if res.partialStreak > 0 {
+		c.reportPartial(res)
+	}
+	if c.onDiskFull != nil && isDiskFull(res.Err) {
+		c.onDiskFull(res.Err)
+	}
+	if c.onCheckpoint != nil {
+		c.onCheckpoint(res)
+	}
+	if c.afterCheckpoint != nil {
+		c.afterCheckpoint(res, res.Err)
+	}
+	c.subs.publish(res)
+	if c.onDurable != nil && !res.durable.IsZero() {
+		c.onDurable(res.durable)
+	}
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// WithIdleCheckpoint performs a Truncate checkpoint once no call to Checkpoint was made for idle,
+// if there were calls since the last checkpoint, so that the WAL is reset during the lulls before the next burst
+// Each call restarts the delay, the checkpoint is skipped while calls are in progress or Pause is in effect
+func WithIdleCheckpoint(idle time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if idle <= 0 {
+			return fmt.Errorf("sqlite: invalid idle duration %v", idle)
+		}
+		c.idle = idle
+		return nil
+	}
+}
+
+// touch records a call to Checkpoint for WithIdleCheckpoint
+func (c *Checkpointer) touch() {
+	if c.idle > 0 {
+		atomic.StoreInt64(&c.lastCall, c.clock.Now().UnixNano())
+	}
+}
+
+// idleCheckpoint is called by the timer of WithIdleCheckpoint, it checkpoints if the idle duration elapsed
+// since the last call and restarts the timer
+func (c *Checkpointer) idleCheckpoint() {
+	c.lock()
+	if c.closed {
+		c.unlock()
+		return
+	}
+	if since := c.since(time.Unix(0, atomic.LoadInt64(&c.lastCall))); since < c.idle {
+		c.idleTimer.Reset(c.idle - since)
+		c.unlock()
+		return
+	}
+	c.idleTimer.Reset(c.idle)
+	if (atomic.LoadUint64(&c.i) == 0 && !c.pending) || c.g.count() > 0 || c.held() {
+		c.unlock()
+		return
+	}
+	if err := c.excludeAuto(context.Background()); err != nil {
+		c.skip()
+		c.unlock()
+		c.report(CheckpointResult{}, err)
+		return
+	}
+	res, err := c.checkpoint(context.Background(), Truncate)
+	c.reset()
+	c.settle()
+	c.unlock()
+	c.outcome(res, err)
+}
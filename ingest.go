@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ingestProfile are the pragmas set by IngestMode
+var ingestProfile = []pragma{
+	{"cache_size", "-262144"},  // 256 MiB
+	{"mmap_size", "268435456"}, // 256 MiB
+	{"temp_store", "memory"},
+	{"synchronous", "off"},
+}
+
+// ingest are the values of the pragmas of ingestProfile before IngestMode, per database
+type ingest struct {
+	saved [][]pragma
+}
+
+// IngestMode prepares a bulk import: it sets cache_size and mmap_size to 256 MiB, temp_store to memory
+// and synchronous to off, and pauses the automatic checkpoints like Pause
+// The returned function restores the previous values of the pragmas, resumes the automatic checkpoints
+// and performs a Truncate checkpoint, Close restores the pragmas if it was not called
+// The pragmas are settings of each connection: they are set on the connection of NewCheckpointerConn or
+// on a connection of the pool, so that IngestMode is intended for the pools of a single connection,
+// like the write database of Pool
+// It returns an error if the ingest mode is already active
+func (c *Checkpointer) IngestMode(ctx context.Context) (restore func() error, err error) {
+	if err := c.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	if c.closed {
+		c.unlock()
+		return nil, ErrClosed
+	}
+	if c.ingest != nil {
+		c.unlock()
+		return nil, errors.New("sqlite: ingest mode already active")
+	}
+	in := &ingest{}
+	for _, d := range c.dbs {
+		saved, err := c.setPragmas(ctx, d, ingestProfile)
+		in.saved = append(in.saved, saved)
+		if err != nil {
+			c.restoreIngest(in)
+			c.unlock()
+			return nil, err
+		}
+	}
+	c.ingest = in
+	c.pauses++
+	c.hold()
+	c.unlock()
+	var once sync.Once
+	return func() error {
+		once.Do(func() { err = c.endIngest(in) })
+		return err
+	}, nil
+}
+
+// endIngest ends the ingest mode in, unless Close ended it
+func (c *Checkpointer) endIngest(in *ingest) error {
+	c.lock()
+	if c.ingest != in || c.closed {
+		c.unlock()
+		return nil
+	}
+	c.ingest = nil
+	err := c.restoreIngest(in)
+	c.unlock()
+	c.Resume()
+	if _, err2 := c.force(context.Background(), Truncate); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// setPragmas sets the pragmas on a connection of d and returns their previous values
+func (c *Checkpointer) setPragmas(ctx context.Context, d *database, pragmas []pragma) ([]pragma, error) {
+	conn, release, err := d.single(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var saved []pragma
+	for _, p := range pragmas {
+		query := schemaPragma(c.schema, p.name)
+		var value string
+		if err := conn.QueryRowContext(ctx, query).Scan(&value); err != nil {
+			return saved, err
+		}
+		if _, err := conn.ExecContext(ctx, query+` = `+p.value); err != nil {
+			return saved, err
+		}
+		saved = append(saved, pragma{p.name, value})
+	}
+	return saved, nil
+}
+
+// restoreIngest restores the pragmas saved by IngestMode, the caller must hold the lock
+func (c *Checkpointer) restoreIngest(in *ingest) error {
+	var errs multiError
+	for i, saved := range in.saved {
+		if _, err := c.setPragmas(context.Background(), c.dbs[i], saved); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.err()
+}
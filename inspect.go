@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Diagnostics is a snapshot of the state of a Checkpointer, see Inspect
+type Diagnostics struct {
+	Counter         uint64         // weight of the calls since the last checkpoint
+	Limit           uint           // see WithLimit and SetLimit
+	Mode            CheckpointMode // mode of the triggered checkpoints
+	InProgress      bool           // a checkpoint was running when Inspect was called
+	Calls           int            // number of calls in progress
+	Pending         bool           // a checkpoint is due and blocks the new calls until it is performed
+	Held            bool           // the automatic checkpoints are held off, see Pause
+	Closed          bool
+	LastResult      CheckpointResult // see LastResult
+	LastError       error            // see LastError
+	SinceCheckpoint time.Duration    // time since the start of the last checkpoint, or since NewCheckpointer
+	SinceSuccess    time.Duration    // time since the start of the last successful checkpoint, 0 if none
+	Throttled       bool             // the calls are blocked by WithBackpressure
+	DiskFull        bool             // the automatic checkpoints are held off by WithDiskFullBackoff, Held is true too
+	UntilTruncate   uint             // number of automatic checkpoints until the Truncate one of WithTruncateEvery, 1 for the next one, 0 without WithTruncateEvery
+}
+
+// Inspect returns a snapshot of the state of the Checkpointer, for instance for a debugging endpoint
+// InProgress is read first, the rest is read under the lock of the Checkpointer, so once the checkpoints
+// performed by the calls to Checkpoint, which hold it, are done
+func (c *Checkpointer) Inspect() Diagnostics {
+	d := Diagnostics{InProgress: atomic.LoadInt32(&c.running) > 0}
+	c.lock()
+	defer c.unlock()
+	d.Counter = atomic.LoadUint64(&c.i)
+	d.Limit = c.Limit()
+	d.Mode = c.mode
+	d.Calls = c.g.count()
+	d.Pending = c.pending
+	d.Held = c.held()
+	d.Closed = c.closed
+	d.LastResult, d.LastError = c.last, c.lastErr
+	d.SinceCheckpoint = c.since(c.lastAt)
+	if !c.stats.LastCheckpointAt.IsZero() {
+		d.SinceSuccess = c.since(c.stats.LastCheckpointAt)
+	}
+	d.Throttled = atomic.LoadInt32(&c.throttled) != 0
+	d.DiskFull = c.backoffTimer != nil
+	d.UntilTruncate = c.untilTruncate
+	return d
+}
+
+// InProgress reports whether a checkpoint is running, without waiting for the lock of the Checkpointer,
+// for instance to report a degraded health while it lasts
+func (c *Checkpointer) InProgress() bool {
+	return atomic.LoadInt32(&c.running) > 0
+}
+
+// PendingWriters returns the number of calls to Checkpoint in progress, without waiting for the lock
+func (c *Checkpointer) PendingWriters() int {
+	return c.g.count()
+}
+
+// SinceLastCheckpoint returns the weight of the calls to Checkpoint and the time since the end of the last
+// checkpoint, or since NewCheckpointer, without waiting for the lock
+// During a checkpoint, both may describe the previous one
+func (c *Checkpointer) SinceLastCheckpoint() (writes uint, elapsed time.Duration) {
+	return uint(atomic.LoadUint64(&c.i)), c.since(time.Unix(0, atomic.LoadInt64(&c.lastEnd)))
+}
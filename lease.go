@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// ErrDoubleRelease is reported with WithLeakDetection when a call to Checkpoint is released twice,
+// the second release does nothing, see Stats.DoubleReleases
+var ErrDoubleRelease = errors.New("sqlite: call to Checkpoint released twice")
+
+// ErrLeakedGuard is reported with WithLeakDetection when a Lease is garbage collected without being released,
+// it is then released, see Stats.LeakedGuards
+var ErrLeakedGuard = errors.New("sqlite: Lease garbage collected without Release")
+
+// WithLeakDetection reports ErrDoubleRelease with the stack trace of the second release, and ErrLeakedGuard
+// with the stack trace of Acquire when a Lease is garbage collected without being released
+// It slows down every call to Acquire, it is intended for debugging
+func WithLeakDetection() Option {
+	return func(c *Checkpointer) error {
+		c.leakDetection = true
+		return nil
+	}
+}
+
+// Lease is a call to Checkpoint in progress, returned by Acquire
+type Lease struct {
+	c        *Checkpointer
+	done     func()
+	released int32
+	stack    []byte // stack trace of Acquire, only with WithLeakDetection
+}
+
+// Acquire is like Checkpoint but returns a Lease, whose Release ends the operation:
+//
+//	l := c.Acquire()
+//	defer l.Release()
+func (c *Checkpointer) Acquire() *Lease {
+	l := &Lease{c: c, done: c.Checkpoint()}
+	if c.leakDetection {
+		l.stack = make([]byte, 4<<10)
+		l.stack = l.stack[:runtime.Stack(l.stack, false)]
+		runtime.SetFinalizer(l, (*Lease).leak)
+	}
+	return l
+}
+
+// Release ends the operation, only its first call does something
+func (l *Lease) Release() {
+	if !atomic.CompareAndSwapInt32(&l.released, 0, 1) {
+		l.c.doubleRelease()
+		return
+	}
+	if l.stack != nil {
+		runtime.SetFinalizer(l, nil)
+	}
+	l.done()
+}
+
+// leak releases a Lease garbage collected without Release
+func (l *Lease) leak() {
+	if !atomic.CompareAndSwapInt32(&l.released, 0, 1) {
+		return
+	}
+	atomic.AddUint64(&l.c.leaks, 1)
+	l.c.report(CheckpointResult{}, fmt.Errorf("%w, acquired at:\n\n%s", ErrLeakedGuard, l.stack))
+	l.done()
+}
+
+// doubleRelease counts a second release of a call to Checkpoint
+func (c *Checkpointer) doubleRelease() {
+	atomic.AddUint64(&c.doubleReleases, 1)
+	if c.leakDetection {
+		stack := make([]byte, 4<<10)
+		stack = stack[:runtime.Stack(stack, false)]
+		c.report(CheckpointResult{}, fmt.Errorf("%w, released again at:\n\n%s", ErrDoubleRelease, stack))
+	}
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives checkpoint failures, it is implemented by *slog.Logger
+type Logger interface {
+	Error(msg string, args ...any)
+}
+
+// WithLogger sets the logger of checkpoint failures, nothing is logged by default or if l is nil
+// The mode, duration, wait, busy flag and page counts of the checkpoint are logged as key-value pairs
+func WithLogger(l Logger) Option {
+	return func(c *Checkpointer) error {
+		c.logger = l
+		return nil
+	}
+}
+
+// WithErrorChannel sets a channel receiving every failure of a checkpoint triggered by Checkpoint
+// The send does not block: the error is dropped if the channel is full, so it should be buffered
+func WithErrorChannel(ch chan<- error) Option {
+	return func(c *Checkpointer) error {
+		c.errCh = ch
+		return nil
+	}
+}
+
+// StdLogger adapts a standard library logger, a nil logger uses the default one of the log package
+func StdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l}
+}
+
+// Logf adapts a printf-like function such as log.Printf or testing.T.Logf
+func Logf(f func(format string, args ...any)) Logger {
+	return logf(f)
+}
+
+type logf func(format string, args ...any)
+
+func (f logf) Error(msg string, args ...any) {
+	f("%s", formatKV(msg, args))
+}
+
+type stdLogger struct{ l *log.Logger }
+
+func (s stdLogger) Error(msg string, args ...any) {
+	s.l.Println(formatKV(msg, args))
+}
+
+// formatKV formats a message followed by key-value pairs
+func formatKV(msg string, args []any) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	return b.String()
+}
@@ -0,0 +1,181 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// WithOptimize runs pragma optimize after every n successful checkpoints, while the calls to Checkpoint are
+// still excluded, so that the statistics of the query planner stay fresh
+// Failures are reported like the failures of the checkpoints
+func WithOptimize(n int) Option {
+	return func(c *Checkpointer) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid optimize period %d", n)
+		}
+		c.optimizeEvery = n
+		return nil
+	}
+}
+
+// Optimize runs pragma optimize on db, see https://www.sqlite.org/pragma.html#pragma_optimize
+func Optimize(ctx context.Context, db *sql.DB) error {
+	return optimize(ctx, db)
+}
+
+func optimize(ctx context.Context, db queryer) error {
+	_, err := db.ExecContext(ctx, `pragma optimize`)
+	return err
+}
+
+// WithAnalyze runs analyze at the first successful checkpoint after every n calls to Checkpoint
+// (their weights with CheckpointN), while the calls to Checkpoint are still excluded, for instance
+// so that the query plans do not degrade after large imports, see WithAnalysisLimit
+// Failures are reported like the failures of the checkpoints, which are still successful, see Stats for the last analyze
+func WithAnalyze(n uint) Option {
+	return func(c *Checkpointer) error {
+		if n == 0 {
+			return fmt.Errorf("sqlite: invalid analyze period %d", n)
+		}
+		c.analyzeEvery = uint64(n)
+		return nil
+	}
+}
+
+// WithAnalysisLimit makes WithAnalyze run pragma optimize with pragma analysis_limit set to limit instead of analyze,
+// so that only the tables that need it are analyzed, each with at most about limit rows
+func WithAnalysisLimit(limit int) Option {
+	return func(c *Checkpointer) error {
+		if limit <= 0 {
+			return fmt.Errorf("sqlite: invalid analysis limit %d", limit)
+		}
+		c.analysisLimit = limit
+		return nil
+	}
+}
+
+// analyze runs analyze, or pragma optimize with WithAnalysisLimit, on d
+func (c *Checkpointer) analyze(ctx context.Context, d *database) error {
+	if c.analysisLimit == 0 {
+		_, err := d.q.ExecContext(ctx, `analyze`)
+		return err
+	}
+	// analysis_limit is a setting of the connection
+	conn, release, err := d.single(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err := conn.ExecContext(ctx, `pragma analysis_limit = `+strconv.Itoa(c.analysisLimit)); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `pragma optimize`)
+	return err
+}
+
+// WithIncrementalVacuum runs pragma incremental_vacuum(pages) after every n successful checkpoints,
+// while the calls to Checkpoint are still excluded, to give the free pages back to the file system
+// A zero pages frees all the free pages
+// The databases must be in incremental auto_vacuum mode, NewCheckpointer fails otherwise
+// Failures are reported like the failures of the checkpoints, see Stats for the freelist sizes
+func WithIncrementalVacuum(n, pages int) Option {
+	return func(c *Checkpointer) error {
+		if n <= 0 || pages < 0 {
+			return fmt.Errorf("sqlite: invalid incremental vacuum policy (every %d checkpoints, %d pages)", n, pages)
+		}
+		c.vacuumEvery, c.vacuumPages = n, pages
+		return nil
+	}
+}
+
+// IncrementalVacuum runs pragma incremental_vacuum(pages) on db, a zero pages frees all the free pages
+func IncrementalVacuum(ctx context.Context, db *sql.DB, pages int) error {
+	return incrementalVacuum(ctx, db, pages)
+}
+
+func incrementalVacuum(ctx context.Context, db queryer, pages int) error {
+	query := `pragma incremental_vacuum`
+	if pages > 0 {
+		query += `(` + strconv.Itoa(pages) + `)`
+	}
+	// The pragma frees a page per step, so it must run to completion
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// checkIncrementalVacuum returns an error if db is not in incremental auto_vacuum mode
+func checkIncrementalVacuum(db queryer) error {
+	var mode int
+	if err := db.QueryRowContext(context.Background(), `pragma auto_vacuum`).Scan(&mode); err != nil {
+		return err
+	}
+	if mode != 2 {
+		return fmt.Errorf("sqlite: incremental vacuum requires auto_vacuum = incremental, it is %d (set it before creating the tables, or run vacuum afterwards)", mode)
+	}
+	return nil
+}
+
+// maintain runs the maintenance due after a successful checkpoint, see WithOptimize, WithAnalyze and WithIncrementalVacuum
+// The caller must hold the lock and exclude the calls to Checkpoint
+func (c *Checkpointer) maintain(ctx context.Context) error {
+	c.successes++
+	if c.dryRun {
+		return nil
+	}
+	var errs multiError
+	if c.optimizeEvery > 0 && c.successes%c.optimizeEvery == 0 {
+		for _, d := range c.dbs {
+			if err := optimize(ctx, d.q); err != nil {
+				errs = append(errs, fmt.Errorf("optimizing: %w", err))
+			}
+		}
+	}
+	// The counter is added to written when it is reset, after the maintenance
+	if written := c.written + atomic.LoadUint64(&c.i); c.analyzeEvery > 0 && written-c.analyzed >= c.analyzeEvery {
+		c.analyzed = written
+		for _, d := range c.dbs {
+			if err := c.analyze(ctx, d); err != nil {
+				errs = append(errs, fmt.Errorf("analyzing: %w", err))
+			}
+		}
+		c.stats.LastAnalyzeAt = time.Now()
+	}
+	if c.vacuumEvery > 0 && c.successes%c.vacuumEvery == 0 {
+		if err := c.vacuum(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("vacuuming: %w", err))
+		}
+	}
+	return errs.err()
+}
+
+// vacuum runs the incremental vacuum on every database and records the freelist sizes, the caller must hold the lock
+func (c *Checkpointer) vacuum(ctx context.Context) error {
+	var before, after int
+	for _, d := range c.dbs {
+		var n int
+		if err := d.q.QueryRowContext(ctx, `pragma freelist_count`).Scan(&n); err != nil {
+			return err
+		}
+		before += n
+		if err := incrementalVacuum(ctx, d.q, c.vacuumPages); err != nil {
+			return err
+		}
+		if err := d.q.QueryRowContext(ctx, `pragma freelist_count`).Scan(&n); err != nil {
+			return err
+		}
+		after += n
+	}
+	c.stats.Vacuums++
+	c.stats.FreelistPagesBefore, c.stats.FreelistPagesAfter = before, after
+	return nil
+}
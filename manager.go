@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Manager holds the checkpointers of several databases, for instance one per tenant,
+// and runs their checkpoints one at a time so that they do not compete for the disk
+// Unlike NewMultiCheckpointer, each database has its own triggers and counters
+type Manager struct {
+	mu     sync.Mutex
+	cs     map[string]*Checkpointer
+	serial chan struct{}
+}
+
+// NewManager returns an empty Manager
+func NewManager() *Manager {
+	return &Manager{cs: make(map[string]*Checkpointer), serial: make(chan struct{}, 1)}
+}
+
+// Register returns a new Checkpointer of db configured by opts like NewCheckpointer, registered under name
+// The name is also the database label of its metrics, see WithName
+func (m *Manager) Register(name string, db *sql.DB, opts ...Option) (*Checkpointer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cs[name]; ok {
+		return nil, fmt.Errorf("sqlite: database %q already registered", name)
+	}
+	c, err := NewCheckpointer(db, append(opts[:len(opts):len(opts)], func(c *Checkpointer) error {
+		c.serial = m.serial
+		c.name = name
+		return nil
+	})...)
+	if err != nil {
+		return nil, err
+	}
+	m.cs[name] = c
+	return c, nil
+}
+
+// Unregister removes the database registered under name and closes its Checkpointer,
+// which waits for the calls in progress and performs a final checkpoint
+func (m *Manager) Unregister(name string) error {
+	m.mu.Lock()
+	c, ok := m.cs[name]
+	delete(m.cs, name)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("sqlite: database %q not registered", name)
+	}
+	return c.Close()
+}
+
+// Checkpointer returns the Checkpointer of the database registered under name, nil if there is none
+func (m *Manager) Checkpointer(name string) *Checkpointer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cs[name]
+}
+
+// Names returns the sorted names of the registered databases
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.cs))
+	for name := range m.cs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stats returns the statistics of every registered database by name
+func (m *Manager) Stats() map[string]Stats {
+	m.mu.Lock()
+	cs := make(map[string]*Checkpointer, len(m.cs))
+	for name, c := range m.cs {
+		cs[name] = c
+	}
+	m.mu.Unlock()
+	stats := make(map[string]Stats, len(cs))
+	for name, c := range cs {
+		stats[name] = c.Stats()
+	}
+	return stats
+}
+
+// Close unregisters every database, closing their checkpointers, and returns the first error
+func (m *Manager) Close() error {
+	var err error
+	for _, name := range m.Names() {
+		if err2 := m.Unregister(name); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckpointMode is the mode given to pragma wal_checkpoint:
+// https://www.sqlite.org/pragma.html#pragma_wal_checkpoint
+type CheckpointMode int
+
+const (
+	// Passive checkpoints as many frames as possible without waiting for readers or writers
+	Passive CheckpointMode = iota + 1
+	// Full blocks new writers and waits for readers, then checkpoints all the frames
+	Full
+	// Restart is like Full and also waits for readers so that the next writer restarts the WAL file from the beginning
+	Restart
+	// Truncate is like Restart and also truncates the WAL file to zero bytes
+	Truncate
+)
+
+// String returns the keyword of the mode as used in the pragma
+func (m CheckpointMode) String() string {
+	switch m {
+	case Passive:
+		return "passive"
+	case Full:
+		return "full"
+	case Restart:
+		return "restart"
+	case Truncate:
+		return "truncate"
+	}
+	return fmt.Sprintf("CheckpointMode(%d)", int(m))
+}
+
+// ParseCheckpointMode returns the mode of the keyword s, as returned by String, for instance read from a configuration file
+// The case is ignored
+func ParseCheckpointMode(s string) (CheckpointMode, error) {
+	for m := Passive; m <= Truncate; m++ {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("sqlite: unknown checkpoint mode %q", s)
+}
+
+func (m CheckpointMode) valid() bool {
+	return m >= Passive && m <= Truncate
+}
+
+// WithMode sets the checkpoint mode, the default is Restart (Passive in WAL2 mode, see WithWAL2)
+// With Passive, busy results are not reported as errors since the checkpoint never waits
+func WithMode(m CheckpointMode) Option {
+	return func(c *Checkpointer) error {
+		if !m.valid() {
+			return fmt.Errorf("sqlite: unknown checkpoint mode %d", int(m))
+		}
+		c.mode = m
+		return nil
+	}
+}
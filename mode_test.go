@@ -0,0 +1,32 @@
+package sqlite
+
+import "testing"
+
+func TestParseCheckpointMode(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want CheckpointMode // 0 for an error
+	}{
+		{"passive", Passive},
+		{"full", Full},
+		{"restart", Restart},
+		{"truncate", Truncate},
+		{"RESTART", Restart},
+		{"Truncate", Truncate},
+		{"", 0},
+		{" restart", 0},
+		{"restart;", 0},
+		{"wal", 0},
+		{"CheckpointMode(5)", 0},
+	} {
+		got, err := ParseCheckpointMode(tt.s)
+		if (err == nil) != (tt.want != 0) || got != tt.want {
+			t.Errorf("ParseCheckpointMode(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+	for m := Passive; m <= Truncate; m++ {
+		if got, err := ParseCheckpointMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseCheckpointMode(%v.String()) = %v, %v", m, got, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync/atomic"
+)
+
+// NewMultiCheckpointer returns a checkpointer that checkpoints all the databases together, see NewCheckpointer
+// The triggers are shared, any WAL file reaching the size set by WithWALSizeLimit triggers a checkpoint of all the databases
+// Results are summed and errors are combined in a single error matched by errors.Is against each of them
+func NewMultiCheckpointer(dbs []*sql.DB, opts ...Option) (*Checkpointer, error) {
+	if len(dbs) == 0 {
+		return nil, errors.New("sqlite: no database to checkpoint")
+	}
+	handles := make([]handle, len(dbs))
+	for i, db := range dbs {
+		handles[i] = db
+	}
+	return newCheckpointer(handles, opts)
+}
+
+// CheckpointFor is like Checkpoint for an operation writing to the database i of NewMultiCheckpointer
+// (0 for the other constructors), it panics if there is no such database
+// Once it is used, the automatic checkpoints leave out the databases without such operations since their
+// last checkpoint, unless there were calls to Checkpoint not attributed to a database meanwhile,
+// see Stats.SkippedDatabases
+func (c *Checkpointer) CheckpointFor(i int) func() {
+	d := c.dbs[i]
+	done, _, _ := c.acquire(context.Background(), 1)
+	// After the checkpoint performed by acquire, if any, which must not reset it
+	atomic.AddUint64(&d.writes, 1)
+	atomic.AddUint64(&c.attributed, 1)
+	return done
+}
+
+// selection tells which databases a checkpoint leaves out, see CheckpointFor
+// It is decided under the lock and handed to run, which may run without it
+type selection struct {
+	selective    bool // the checkpoint is automatic and CheckpointFor was called
+	unattributed bool // there were calls to Checkpoint not attributed to a database, no database is left out
+
+	only   *database // the only database checkpointed, see CheckpointSchemas, nil for all of them
+	schema string    // schema checkpointed instead of the one of WithSchema, see CheckpointSchemas
+}
+
+// selection returns the selection of the next checkpoint, automatic if auto is true or after autoMode,
+// and restarts the attribution of the calls, the caller must hold the lock
+func (c *Checkpointer) selection(auto bool) selection {
+	auto = auto || c.selective
+	c.selective = false
+	attributed := atomic.SwapUint64(&c.attributed, 0)
+	return selection{selective: auto && attributed > 0, unattributed: atomic.LoadUint64(&c.i) > attributed}
+}
+
+// skips reports whether the checkpoint leaves d out
+func (s selection) skips(d *database) bool {
+	return s.selective && !s.unattributed && atomic.LoadUint64(&d.writes) == 0
+}
+
+// add sums the page counts of r, ignoring the -1 of databases that are not in WAL mode
+func (res *CheckpointResult) add(r CheckpointResult) {
+	res.Busy = res.Busy || r.Busy
+	if r.WALPages >= 0 {
+		res.WALPages = max0(res.WALPages) + r.WALPages
+	}
+	if r.CheckpointedPages >= 0 {
+		res.CheckpointedPages = max0(res.CheckpointedPages) + r.CheckpointedPages
+	}
+}
+
+func max0(i int) int {
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// multiError combines the errors of several databases
+type multiError []error
+
+func (errs multiError) err() error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errs
+}
+
+func (errs multiError) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+func (errs multiError) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (errs multiError) Unwrap() []error {
+	return errs
+}
@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckpointForConcurrentPassive(t *testing.T) {
+	dbs := []*sql.DB{openTest(t, "a.db"), openTest(t, "b.db")}
+	c, err := NewMultiCheckpointer(dbs, WithLimit(3), WithConcurrentPassive(), WithInterval(time.Microsecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				done := c.CheckpointFor(i % len(dbs))
+				_, err := dbs[i%len(dbs)].Exec(`insert into t values (1)`)
+				done()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.TotalCheckpoints == 0 {
+		t.Errorf("no checkpoint: %v", s)
+	}
+}
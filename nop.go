@@ -0,0 +1,40 @@
+package sqlite
+
+import "context"
+
+// Guard is the method wrapping the writes, implemented by *Checkpointer and by Nop,
+// for the code that does not need more
+type Guard interface {
+	Checkpoint() func()
+}
+
+// CheckpointManager is implemented by *Checkpointer and by Nop, for the code that also controls the checkpoints,
+// so that it can be given a fake in tests
+type CheckpointManager interface {
+	Guard
+	CheckpointContext(ctx context.Context) (func(), error)
+	CheckpointN(n uint) func()
+	Force(mode CheckpointMode) error
+	Stats() Stats
+	Close() error
+}
+
+var (
+	_ CheckpointManager = (*Checkpointer)(nil)
+	_ CheckpointManager = Nop()
+)
+
+// Nop returns a CheckpointManager that never checkpoints, for instance for the tests that do not use a database
+// Its guards are no-op functions, Force and Close do nothing and Stats returns zero statistics
+func Nop() CheckpointManager {
+	return nopCheckpointer{}
+}
+
+type nopCheckpointer struct{}
+
+func (nopCheckpointer) Checkpoint() func()                                    { return nop }
+func (nopCheckpointer) CheckpointContext(ctx context.Context) (func(), error) { return nop, nil }
+func (nopCheckpointer) CheckpointN(n uint) func()                             { return nop }
+func (nopCheckpointer) Force(mode CheckpointMode) error                       { return nil }
+func (nopCheckpointer) Stats() Stats                                          { return Stats{} }
+func (nopCheckpointer) Close() error                                          { return nil }
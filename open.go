@@ -0,0 +1,248 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// OpenOption configures Open
+type OpenOption func(*openConfig) error
+
+type openConfig struct {
+	driver       string
+	txLock       string
+	pragmas      []pragma
+	checkpointed bool // the database is checkpointed by a Checkpointer, see withCheckpointer
+	readOnly     bool // see OpenReadOnly
+	immutable    bool // see Immutable
+}
+
+// withCheckpointer disables wal_autocheckpoint on every connection, as required by NewCheckpointer,
+// unless the pragma is set explicitly
+func withCheckpointer() OpenOption {
+	return func(o *openConfig) error {
+		o.checkpointed = true
+		return nil
+	}
+}
+
+// pragma returns the value of the pragma name, "" if it is not set
+func (o *openConfig) pragma(name string) string {
+	for _, p := range o.pragmas {
+		if strings.EqualFold(p.name, name) {
+			return p.value
+		}
+	}
+	return ""
+}
+
+type pragma struct{ name, value string }
+
+// WithDriver sets the name of the database/sql driver used by Open: "sqlite" for modernc.org/sqlite
+// or "sqlite3" for github.com/mattn/go-sqlite3, the DSN syntax of the pragmas depends on it
+// By default, the first of them that is registered is used
+func WithDriver(name string) OpenOption {
+	return func(o *openConfig) error {
+		if name != "sqlite" && name != "sqlite3" {
+			return fmt.Errorf("sqlite: unsupported driver %q", name)
+		}
+		o.driver = name
+		return nil
+	}
+}
+
+// WithPragma sets a pragma applied to every connection opened by Open, overriding the default value if any
+// The defaults are journal_mode = wal, synchronous = normal, busy_timeout = 5000 and foreign_keys = on
+// The pragmas and values are those accepted by NewConnector, since they are written in the DSN and in the queries
+// checking them
+// With github.com/mattn/go-sqlite3, only the pragmas that have a DSN parameter are supported, and wal_autocheckpoint,
+// which is set by the connector of the pool like NewConnector does
+func WithPragma(name, value string) OpenOption {
+	return func(o *openConfig) error {
+		if err := (Pragma{name, value}).validate(); err != nil {
+			return err
+		}
+		for i := range o.pragmas {
+			if strings.EqualFold(o.pragmas[i].name, name) {
+				o.pragmas[i].value = value
+				return nil
+			}
+		}
+		o.pragmas = append(o.pragmas, pragma{name, value})
+		return nil
+	}
+}
+
+// WithTxLock sets the locking mode of the transactions opened by Open: "deferred" (the default of SQLite),
+// "immediate" or "exclusive"
+// Immediate transactions take the write lock when they begin, so that writes cannot fail with a busy error
+// when upgrading a read transaction after SQLite gave up waiting for the lock, Retry and WithTx require them
+// With modernc.org/sqlite, the transactions begun with sql.TxOptions{ReadOnly: true} stay deferred, so that
+// the readers do not wait for the write lock
+func WithTxLock(mode string) OpenOption {
+	return func(o *openConfig) error {
+		switch mode {
+		case "deferred", "immediate", "exclusive":
+		default:
+			return fmt.Errorf("sqlite: unknown transaction locking mode %q", mode)
+		}
+		o.txLock = mode
+		return nil
+	}
+}
+
+// Open opens the database file at path with the recommended pragmas for concurrent use, see WithPragma,
+// and checks that they are in effect
+func Open(path string, opts ...OpenOption) (*sql.DB, error) {
+	o := openConfig{pragmas: []pragma{
+		{"journal_mode", "wal"},
+		{"synchronous", "normal"},
+		{"busy_timeout", "5000"},
+		{"foreign_keys", "on"},
+	}}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	return o.open(path)
+}
+
+// open opens the database file at path with the configuration o and checks the pragmas
+func (o *openConfig) open(path string) (*sql.DB, error) {
+	if o.immutable && !o.readOnly {
+		return nil, fmt.Errorf("sqlite: Immutable requires OpenReadOnly")
+	}
+	if o.driver == "" {
+		if o.driver = defaultDriver(); o.driver == "" {
+			return nil, fmt.Errorf(`sqlite: neither the "sqlite" nor the "sqlite3" driver is registered`)
+		}
+	}
+	// WAL2 switches to the other WAL file when the current one reaches wal_autocheckpoint pages
+	if o.checkpointed && o.pragma("wal_autocheckpoint") == "" && !strings.EqualFold(o.pragma("journal_mode"), "wal2") {
+		o.pragmas = append(o.pragmas, pragma{"wal_autocheckpoint", "0"})
+	}
+	if strings.ContainsRune(path, '?') {
+		return nil, fmt.Errorf("sqlite: invalid path %q, the DSN parameters are set by Open", path)
+	}
+	v := url.Values{}
+	var connPragmas []Pragma
+	for _, p := range o.pragmas {
+		// No DSN parameter of github.com/mattn/go-sqlite3 sets it, and NewCheckpointer finds it in the connector
+		if strings.EqualFold(p.name, "wal_autocheckpoint") {
+			connPragmas = append(connPragmas, Pragma{p.name, p.value})
+			continue
+		}
+		if o.driver == "sqlite" {
+			v.Add("_pragma", p.name+"("+p.value+")")
+		} else {
+			v.Add("_"+p.name, p.value)
+		}
+	}
+	if o.txLock != "" {
+		v.Set("_txlock", o.txLock)
+	}
+	dsn := path
+	if o.readOnly {
+		// Both drivers pass the URI parameters they do not know to SQLite
+		dsn = "file:" + uriPath.Replace(path)
+		v.Set("mode", "ro")
+		if o.immutable {
+			v.Set("immutable", "1")
+		}
+	}
+	db, err := sql.Open(o.driver, dsn+"?"+v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if db, err = withConnector(db, dsn+"?"+v.Encode(), connPragmas, o.txLock); err != nil {
+		return nil, err
+	}
+	for _, p := range o.pragmas {
+		var got string
+		if err := db.QueryRow(`pragma ` + p.name).Scan(&got); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("sqlite: checking pragma %s: %w", p.name, err)
+		}
+		if !strings.EqualFold(got, pragmaValue(p.name, p.value)) {
+			db.Close()
+			return nil, fmt.Errorf("sqlite: pragma %s is %q instead of %q", p.name, got, p.value)
+		}
+	}
+	return db, nil
+}
+
+// withConnector reopens db, which has no connection yet, with NewConnector setting pragmas on every connection
+// and recording the locking mode of its transactions, see WithTxLock
+func withConnector(db *sql.DB, dsn string, pragmas []Pragma, txLock string) (*sql.DB, error) {
+	defer db.Close()
+	var base driver.Connector = dsnConnector{db.Driver(), dsn}
+	if d, ok := db.Driver().(driver.DriverContext); ok {
+		var err error
+		if base, err = d.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	c := NewConnector(base, pragmas...).(*connector)
+	c.txLock = txLock
+	return sql.OpenDB(c), nil
+}
+
+// dsnConnector is the connector of sql.Open for the drivers that are not a driver.DriverContext
+type dsnConnector struct {
+	d   driver.Driver
+	dsn string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open(c.dsn) }
+
+func (c dsnConnector) Driver() driver.Driver { return c.d }
+
+// OpenWithCheckpointer is like Open and also returns a Checkpointer for the database, configured by checkpointOpts
+// wal_autocheckpoint is disabled on every connection of the pool, as required by NewCheckpointer,
+// and the transactions are immediate unless WithTxLock says otherwise, as required by WithTx
+// Close the Checkpointer before the database
+func OpenWithCheckpointer(path string, checkpointOpts []Option, opts ...OpenOption) (*sql.DB, *Checkpointer, error) {
+	db, err := Open(path, append([]OpenOption{withCheckpointer(), WithTxLock("immediate")}, opts...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := NewCheckpointer(db, checkpointOpts...)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, c, nil
+}
+
+func defaultDriver() string {
+	for _, name := range []string{"sqlite", "sqlite3"} {
+		for _, d := range sql.Drivers() {
+			if d == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// pragmaValue returns value as read back from the pragma, for the pragmas storing keywords as numbers
+func pragmaValue(name, value string) string {
+	var values map[string]string
+	switch strings.ToLower(name) {
+	case "synchronous":
+		values = map[string]string{"off": "0", "normal": "1", "full": "2", "extra": "3"}
+	case "foreign_keys", "recursive_triggers", "query_only", "secure_delete":
+		values = map[string]string{"off": "0", "false": "0", "no": "0", "on": "1", "true": "1", "yes": "1"}
+	case "auto_vacuum":
+		values = map[string]string{"none": "0", "full": "1", "incremental": "2"}
+	}
+	if v, ok := values[strings.ToLower(value)]; ok {
+		return v
+	}
+	return value
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWithPragma(t *testing.T) {
+	for _, tt := range []struct {
+		name, value string
+		ok          bool
+	}{
+		{"cache_size", "-2000", true},
+		{"temp_store", "memory", true},
+		{"Synchronous", "FULL", true},
+		{"", "1", false},
+		{"unknown_pragma", "1", false},
+		{"cache_size; drop table t", "1", false},
+		{"cache_size", "", false},
+		{"cache_size", "1; drop table t", false},
+		{"cache_size", "1)&_pragma=query_only(1", false},
+		{"journal_mode", "'wal'", false},
+	} {
+		o := openConfig{}
+		if err := WithPragma(tt.name, tt.value)(&o); (err == nil) != tt.ok {
+			t.Errorf("WithPragma(%q, %q): %v", tt.name, tt.value, err)
+		}
+	}
+}
+
+func TestOpenWithPragma(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"), WithPragma("cache_size", "-1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var got int
+	if err := db.QueryRow(`pragma cache_size`).Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != -1000 {
+		t.Errorf("cache_size = %d", got)
+	}
+}
+
+func TestAutocheckpointEveryConn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, c, err := OpenWithCheckpointer(path, []Option{WithLimit(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	defer c.Close()
+	// Holding the connections until all are checked makes the pool open a new one for each goroutine
+	const n = 8
+	var wg sync.WaitGroup
+	conns := make(chan *sql.Conn, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := db.Conn(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conns <- conn
+			var got int
+			if err := conn.QueryRowContext(context.Background(), `pragma wal_autocheckpoint`).Scan(&got); err != nil {
+				t.Error(err)
+			} else if got != 0 {
+				t.Errorf("wal_autocheckpoint = %d", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if s := db.Stats(); s.OpenConnections < n {
+		t.Errorf("%d connections checked instead of %d", s.OpenConnections, n)
+	}
+	close(conns)
+	for conn := range conns {
+		conn.Close()
+	}
+
+	// The pool of Open checkpoints automatically
+	plain, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := NewCheckpointer(plain, WithLimit(10)); !errors.Is(err, ErrAutocheckpoint) {
+		t.Errorf("NewCheckpointer of a plain pool returned %v", err)
+	}
+}
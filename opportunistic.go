@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WithOpportunistic makes the checkpoints triggered by Checkpoint passive, so that they do not wait for the calls
+// in progress, unless the WAL holds more than ceiling pages: then a checkpoint with the mode of the Checkpointer
+// follows, waiting for the calls in progress as usual
+// The maintenance of WithOptimize and WithIncrementalVacuum only follows the latter
+func WithOpportunistic(ceiling int) Option {
+	return func(c *Checkpointer) error {
+		if ceiling <= 0 {
+			return fmt.Errorf("sqlite: invalid WAL pages ceiling %d", ceiling)
+		}
+		c.ceiling = ceiling
+		return nil
+	}
+}
+
+// opportunistic performs a passive checkpoint and escalates if the WAL is still above the ceiling,
+// the caller must hold the lock
+func (c *Checkpointer) opportunistic(ctx context.Context) (CheckpointResult, error) {
+	sel := c.selection(true)
+	c.reset()
+	start := c.clock.Now()
+	c.lastAt = start
+	res, err := c.run(ctx, Passive, sel)
+	c.save(start, res, err)
+	if err != nil || res.WALPages <= c.ceiling {
+		// Unblocks the gate left blocked by SetLimit or Resume for instance, like the blocking checkpoints
+		c.settle()
+		return res, err
+	}
+	if err := c.excludeAuto(ctx); errors.Is(err, ErrGuardTimeout) {
+		c.skip()
+		return res, err
+	} else if err != nil {
+		c.pending = true
+		return res, err
+	}
+	res, err = c.checkpoint(ctx, c.autoMode())
+	c.reset()
+	c.settle()
+	return res, err
+}
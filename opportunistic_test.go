@@ -0,0 +1,32 @@
+package sqlite
+
+import "testing"
+
+func TestOpportunisticSettle(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(100), WithOpportunistic(1000))
+	for i := 0; i < 5; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	// The calls already reach the new limit: the gate is blocked until the next checkpoint
+	c.SetLimit(3)
+	if !c.g.isBlocked() {
+		t.Fatal("gate not blocked by SetLimit")
+	}
+	done := c.Checkpoint()
+	insert(t, db)
+	done()
+	if n := c.Stats().TotalCheckpoints; n != 1 {
+		t.Fatalf("%d checkpoints instead of 1", n)
+	}
+	if c.g.isBlocked() {
+		t.Error("gate still blocked after the passive checkpoint, the calls take the locked path")
+	}
+	if !c.fast(1) {
+		t.Error("call below the limit not on the fast path")
+	} else {
+		c.g.leave()
+	}
+}
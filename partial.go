@@ -0,0 +1,60 @@
+package sqlite
+
+import "fmt"
+
+// PartialPolicy configures WithPartialPolicy
+type PartialPolicy struct {
+	After     int                               // consecutive partial checkpoints before acting
+	Escalate  bool                              // the automatic checkpoints then use at least Restart, which blocks the new readers
+	OnPartial func(n int, res CheckpointResult) // called with the number of partial checkpoints in a row and the last result
+}
+
+// WithPartialPolicy acts once p.After checkpoints in a row did not write back the whole WAL (see CheckpointResult.Partial),
+// typically because a long read transaction holds a snapshot: it calls p.OnPartial after the checkpoint, outside the lock,
+// and with p.Escalate the automatic checkpoints use at least Restart until one writes back the whole WAL
+// The decision is logged with the page counts of the checkpoint by the logger of WithLogger, see Stats.ConsecutivePartial
+func WithPartialPolicy(p PartialPolicy) Option {
+	return func(c *Checkpointer) error {
+		if p.After <= 0 {
+			return fmt.Errorf("sqlite: invalid number of partial checkpoints %d", p.After)
+		}
+		c.partialPolicy = p
+		return nil
+	}
+}
+
+// countPartial counts the partial checkpoints in a row, the caller must hold the lock
+func (c *Checkpointer) countPartial(res CheckpointResult, err error) {
+	if err != nil && !isBusy(err) {
+		return
+	}
+	if !res.Partial() {
+		c.stats.ConsecutivePartial = 0
+		c.partialEscalated = false
+		return
+	}
+	c.stats.ConsecutivePartial++
+	if c.partialPolicy.After > 0 && c.stats.ConsecutivePartial == c.partialPolicy.After {
+		c.partialEscalated = c.partialPolicy.Escalate
+		c.partialStreak = c.stats.ConsecutivePartial
+	}
+}
+
+// reportPartial logs the decision of WithPartialPolicy and calls its function, the caller must not hold the lock
+func (c *Checkpointer) reportPartial(res CheckpointResult) {
+	if c.logger != nil {
+		msg := "checkpoints partial"
+		if c.partialPolicy.Escalate {
+			msg += ", escalating to restart"
+		}
+		c.logger.Error(msg,
+			"partial_checkpoints", res.partialStreak,
+			"mode", res.Mode,
+			"wal_pages", res.WALPages,
+			"checkpointed_pages", res.CheckpointedPages,
+		)
+	}
+	if c.partialPolicy.OnPartial != nil {
+		c.partialPolicy.OnPartial(res.partialStreak, res)
+	}
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// NewBackgroundCheckpointer returns a Checkpointer that does not require wrapping the writes with Checkpoint:
+// a goroutine checks the WAL of db every interval and checkpoints it when it holds more than threshold pages
+// The check is a passive checkpoint, it never waits for readers or writers
+// A checkpoint using the mode of the Checkpointer follows only if the check could not write back every page
+// The goroutine is stopped by Close
+func NewBackgroundCheckpointer(db *sql.DB, threshold int, interval time.Duration, opts ...Option) (*Checkpointer, error) {
+	if threshold <= 0 {
+		return nil, fmt.Errorf("sqlite: invalid WAL pages threshold %d", threshold)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("sqlite: invalid poll interval %v", interval)
+	}
+	return newCheckpointer([]handle{db}, append([]Option{func(c *Checkpointer) error {
+		c.pollPages, c.pollInterval = threshold, interval
+		return nil
+	}}, opts...))
+}
+
+func (c *Checkpointer) poll() {
+	defer c.bg.Done()
+	jitter := c.jittered()
+	t := time.NewTimer(jitter(c.pollInterval))
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			c.pollOnce()
+			t.Reset(jitter(c.pollInterval))
+		}
+	}
+}
+
+// pollOnce checkpoints if the passive check left more than pollPages pages in the WAL
+func (c *Checkpointer) pollOnce() {
+	over, err := c.walOver(c.pollPages)
+	if err != nil {
+		c.report(CheckpointResult{}, err)
+		return
+	}
+	if !over {
+		return
+	}
+	c.lock()
+	if c.closed || c.held() || c.debounced() {
+		c.unlock()
+		return
+	}
+	if err := c.excludeAuto(context.Background()); err != nil {
+		c.skip()
+		c.unlock()
+		c.report(CheckpointResult{}, err)
+		return
+	}
+	res, err := c.checkpoint(context.Background(), c.autoMode())
+	c.reset()
+	c.settle()
+	c.unlock()
+	c.outcome(res, err)
+}
+
+// walOver runs a passive checkpoint of each database and returns true if a WAL holds more than pages pages,
+// some of them not written back
+func (c *Checkpointer) walOver(pages int) (bool, error) {
+	for i, d := range c.dbs {
+		res, err := checkpoint(context.Background(), d.q, c.schema, Passive)
+		if err != nil {
+			return false, newCheckpointError(i, len(c.dbs) > 1, res, err)
+		}
+		if res.WALPages > pages && res.CheckpointedPages < res.WALPages {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// WALPages returns the number of pages in the WAL, summed over the databases, -1 if they are not in WAL mode
+// It runs a passive checkpoint, which never waits for readers or writers,
+// and neither waits for the wrapped operations nor updates the counter and the statistics
+func (c *Checkpointer) WALPages() (int, error) {
+	res := CheckpointResult{WALPages: -1}
+	for i, d := range c.dbs {
+		r, err := checkpoint(context.Background(), d.q, c.schema, Passive)
+		if err != nil {
+			if len(c.dbs) > 1 {
+				err = fmt.Errorf("database %d: %w", i, err)
+			}
+			return 0, err
+		}
+		res.add(r)
+	}
+	return res.WALPages, nil
+}
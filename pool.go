@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Pool separates the writes, serialized on a single connection, from the reads, which use many connections,
+// to avoid the busy errors of concurrent writers
+// The writes are counted by a Checkpointer
+type Pool struct {
+	read, write *sql.DB
+	c           *Checkpointer
+	mu          sync.Mutex // held by Write during its transaction, see ReadAfterWrite
+}
+
+// PoolOption configures NewPool
+type PoolOption func(*poolConfig) error
+
+type poolConfig struct {
+	readConns    int
+	open         []OpenOption
+	checkpointer []Option
+}
+
+// WithCheckpointLimit checkpoints every limit writes, see WithLimit, the default is 1000
+func WithCheckpointLimit(limit uint) PoolOption {
+	return WithCheckpointerOptions(WithLimit(limit))
+}
+
+// WithCheckpointerOptions adds options to the Checkpointer of the pool
+func WithCheckpointerOptions(opts ...Option) PoolOption {
+	return func(p *poolConfig) error {
+		p.checkpointer = append(p.checkpointer, opts...)
+		return nil
+	}
+}
+
+// WithOpenOptions adds options to Open for both handles of the pool
+func WithOpenOptions(opts ...OpenOption) PoolOption {
+	return func(p *poolConfig) error {
+		p.open = append(p.open, opts...)
+		return nil
+	}
+}
+
+// WithReadConns sets the maximum number of read connections, the default is the number of CPUs
+func WithReadConns(n int) PoolOption {
+	return func(p *poolConfig) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid number of read connections %d", n)
+		}
+		p.readConns = n
+		return nil
+	}
+}
+
+// NewPool opens the database file at path twice with Open: once for the writes, with a single connection
+// and immediate transactions unless WithTxLock says otherwise, and once for the reads, with query_only set
+func NewPool(path string, opts ...PoolOption) (*Pool, error) {
+	p := poolConfig{readConns: runtime.NumCPU(), checkpointer: []Option{WithLimit(1000)}}
+	for _, opt := range opts {
+		if err := opt(&p); err != nil {
+			return nil, err
+		}
+	}
+	write, err := Open(path, append([]OpenOption{withCheckpointer(), WithTxLock("immediate")}, p.open...)...)
+	if err != nil {
+		return nil, err
+	}
+	write.SetMaxOpenConns(1)
+	c, err := NewCheckpointer(write, p.checkpointer...)
+	if err != nil {
+		write.Close()
+		return nil, err
+	}
+	read, err := Open(path, append(p.open[:len(p.open):len(p.open)], WithPragma("query_only", "on"))...)
+	if err != nil {
+		c.Close()
+		write.Close()
+		return nil, err
+	}
+	read.SetMaxOpenConns(p.readConns)
+	read.SetMaxIdleConns(p.readConns)
+	return &Pool{read: read, write: write, c: c}, nil
+}
+
+// Read runs fn in a transaction on a read connection, the transaction is always rolled back
+func (p *Pool) Read(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := p.read.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+// Write runs fn in a transaction on the write connection, counted by the Checkpointer
+// The transaction is committed if fn returns nil and rolled back otherwise or if fn panics
+func (p *Pool) Write(ctx context.Context, fn func(*sql.Tx) error) error {
+	done, err := p.c.CheckpointContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return runTx(ctx, p.write, fn)
+}
+
+// ReadAfterWrite is like Read but its transaction observes every call to Write that returned before it,
+// even from another goroutine, and the one whose transaction is in progress when it is called, if any
+// It waits for that transaction and starts the read transaction by reading the schema before the next one,
+// so that the snapshot of the connection is taken after the commit and not when fn first reads
+// The writes on WriteDB that bypass Write are not waited for
+func (p *Pool) ReadAfterWrite(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := p.read.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	p.mu.Lock()
+	var n int
+	err = tx.QueryRowContext(ctx, `select count(*) from sqlite_master`).Scan(&n)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return fn(tx)
+}
+
+// Checkpointer returns the Checkpointer of the writes
+func (p *Pool) Checkpointer() *Checkpointer { return p.c }
+
+// ReadDB returns the handle of the reads
+func (p *Pool) ReadDB() *sql.DB { return p.read }
+
+// WriteDB returns the handle of the writes, the operations on it should be wrapped by the Checkpointer
+func (p *Pool) WriteDB() *sql.DB { return p.write }
+
+// Close closes the Checkpointer, which performs a last checkpoint, and both handles
+func (p *Pool) Close() error {
+	var errs multiError
+	for _, f := range []func() error{p.c.Close, p.write.Close, p.read.Close} {
+		if err := f(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.err()
+}
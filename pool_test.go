@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T) *Pool {
+	t.Helper()
+	p, err := NewPool(filepath.Join(t.TempDir(), "test.db"), WithCheckpointLimit(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	if _, err := p.WriteDB().Exec(`create table t (v)`); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPoolWritePanic(t *testing.T) {
+	p := newTestPool(t)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("no panic")
+			}
+		}()
+		p.Write(context.Background(), func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`insert into t values (1)`); err != nil {
+				return err
+			}
+			panic("write failed")
+		})
+	}()
+	// The write connection is the only one, it is left in a transaction if the panic does not roll it back
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Write(ctx, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`insert into t values (2)`)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := p.ReadDB().QueryRow(`select count(*) from t`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d rows, want 1", n)
+	}
+}
+
+func TestReadAfterWrite(t *testing.T) {
+	p := newTestPool(t)
+	ctx := context.Background()
+	if _, err := p.WriteDB().Exec(`insert into t values (0)`); err != nil {
+		t.Fatal(err)
+	}
+	var written int64 // last value whose Write returned, accessed atomically
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last int64
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				want := atomic.LoadInt64(&written)
+				var v int64
+				if err := p.ReadAfterWrite(ctx, func(tx *sql.Tx) error {
+					return tx.QueryRow(`select v from t`).Scan(&v)
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+				if v < want || v < last {
+					t.Errorf("read %d after the write of %d and the read of %d", v, want, last)
+					return
+				}
+				last = v
+			}
+		}()
+	}
+	for i := int64(1); i <= 200; i++ {
+		if err := p.Write(ctx, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`update t set v = ?`, i)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreInt64(&written, i)
+	}
+}
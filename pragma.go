@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// knownPragmas are the pragmas accepted by NewConnector and SetPragma, the settings of each connection
+var knownPragmas = map[string]bool{
+	"analysis_limit":     true,
+	"auto_vacuum":        true,
+	"automatic_index":    true,
+	"busy_timeout":       true,
+	"cache_size":         true,
+	"cache_spill":        true,
+	"cell_size_check":    true,
+	"defer_foreign_keys": true,
+	"foreign_keys":       true,
+	"journal_mode":       true,
+	"journal_size_limit": true,
+	"locking_mode":       true,
+	"mmap_size":          true,
+	"query_only":         true,
+	"recursive_triggers": true,
+	"secure_delete":      true,
+	"synchronous":        true,
+	"temp_store":         true,
+	"wal_autocheckpoint": true,
+}
+
+// validate checks that the name is in the list of NewConnector and that the value is a keyword or an integer
+func (p Pragma) validate() error {
+	if !knownPragmas[strings.ToLower(p.Name)] {
+		return fmt.Errorf("sqlite: unsupported pragma %q", p.Name)
+	}
+	v := strings.TrimPrefix(p.Value, "-")
+	if v == "" || strings.IndexFunc(v, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_')
+	}) >= 0 {
+		return fmt.Errorf("sqlite: invalid value %q of pragma %s", p.Value, p.Name)
+	}
+	return nil
+}
+
+// Execer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SetPragma sets the pragma name to value and returns its value as read back, failing if it differs,
+// for instance for journal_mode = wal on a read-only database, which keeps its mode
+// The pragmas and values are those accepted by NewConnector
+// The pragmas are settings of each connection: with a *sql.DB, only one connection of the pool is set
+func SetPragma(ctx context.Context, e Execer, name, value string) (applied string, err error) {
+	if err := (Pragma{name, value}).validate(); err != nil {
+		return "", err
+	}
+	if _, err := e.ExecContext(ctx, `pragma `+name+` = `+value); err != nil {
+		return "", fmt.Errorf("sqlite: setting pragma %s: %w", name, err)
+	}
+	if err := e.QueryRowContext(ctx, `pragma `+name).Scan(&applied); err != nil {
+		return "", fmt.Errorf("sqlite: checking pragma %s: %w", name, err)
+	}
+	if !strings.EqualFold(applied, pragmaValue(name, value)) {
+		return applied, fmt.Errorf("sqlite: pragma %s is %q instead of %q", name, applied, value)
+	}
+	return applied, nil
+}
+
+// parseBool parses the value of a boolean pragma, which the drivers return as an integer or as a string
+// (scanned as a string, "0" or "1") and which may also be set as on, off, true, false, yes or no
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "0", "off", "false", "no":
+		return false, nil
+	case "1", "on", "true", "yes":
+		return true, nil
+	}
+	return false, fmt.Errorf("sqlite: invalid boolean %q", value)
+}
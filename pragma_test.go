@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseBool(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		want    bool
+		invalid bool
+	}{
+		{"0", false, false},
+		{"1", true, false},
+		{"on", true, false},
+		{"OFF", false, false},
+		{"true", true, false},
+		{"No", false, false},
+		{"yes", true, false},
+		{"", false, true},
+		{"2", false, true},
+		{"wal", false, true},
+		{"delete", false, true},
+	} {
+		got, err := parseBool(tt.value)
+		if (err != nil) != tt.invalid || got != tt.want {
+			t.Errorf("parseBool(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+// TestParseBoolDriver parses the answers of the driver, integers for the boolean pragmas,
+// scanned as strings like by the checks of this package, and as sql.RawBytes
+func TestParseBoolDriver(t *testing.T) {
+	db := openTest(t, "test.db")
+	db.SetMaxOpenConns(1)
+	for _, tt := range []struct {
+		set  string
+		want bool
+	}{
+		{`pragma query_only = on`, true},
+		{`pragma query_only = off`, false},
+		{`pragma query_only = 1`, true},
+		{`pragma query_only = false`, false},
+	} {
+		if _, err := db.Exec(tt.set); err != nil {
+			t.Fatal(err)
+		}
+		var s string
+		if err := db.QueryRow(`pragma query_only`).Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseBool(s); err != nil || got != tt.want {
+			t.Errorf("%s: parseBool(%q) = %v, %v", tt.set, s, got, err)
+		}
+		rows, err := db.Query(`pragma query_only`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var raw sql.RawBytes
+			if err := rows.Scan(&raw); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := parseBool(string(raw)); err != nil || got != tt.want {
+				t.Errorf("%s: parseBool(%q) of sql.RawBytes = %v, %v", tt.set, raw, got, err)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+	// journal_mode is answered as text, not as a boolean
+	var mode string
+	if err := db.QueryRow(`pragma journal_mode`).Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseBool(mode); mode != "wal" || err == nil {
+		t.Errorf("journal_mode %q parsed as a boolean: %v", mode, err)
+	}
+}
+
+// valueDriver is a driver whose queries return a single row with the value v,
+// to scan the representations of the different drivers
+type valueDriver struct{ v driver.Value }
+
+func (d valueDriver) Open(string) (driver.Conn, error) { return d, nil }
+
+func (d valueDriver) Prepare(string) (driver.Stmt, error) { return d, nil }
+
+func (d valueDriver) Close() error { return nil }
+
+func (d valueDriver) Begin() (driver.Tx, error) { return nil, errors.New("no transaction") }
+
+func (d valueDriver) NumInput() int { return -1 }
+
+func (d valueDriver) Exec([]driver.Value) (driver.Result, error) { return nil, errors.New("no exec") }
+
+func (d valueDriver) Query([]driver.Value) (driver.Rows, error) { return &valueRows{v: d.v}, nil }
+
+type valueRows struct {
+	v    driver.Value
+	done bool
+}
+
+func (r *valueRows) Columns() []string { return []string{"value"} }
+
+func (r *valueRows) Close() error { return nil }
+
+func (r *valueRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.v
+	return nil
+}
+
+func TestParseBoolValues(t *testing.T) {
+	for _, tt := range []struct {
+		v    driver.Value
+		want bool
+	}{
+		{int64(1), true},
+		{int64(0), false},
+		{[]byte("1"), true},
+		{[]byte("off"), false},
+		{"on", true},
+		{true, true},
+		{false, false},
+	} {
+		db := sql.OpenDB(driverConnector{valueDriver{tt.v}})
+		var s string
+		if err := db.QueryRow(`pragma query_only`).Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := parseBool(s); err != nil || got != tt.want {
+			t.Errorf("%T %v: parseBool(%q) = %v, %v", tt.v, tt.v, s, got, err)
+		}
+		if err := checkWritable(db); (err == ErrReadOnly) != tt.want {
+			t.Errorf("%T %v: checkWritable returned %v", tt.v, tt.v, err)
+		}
+		db.Close()
+	}
+}
+
+type driverConnector struct{ d driver.Driver }
+
+func (c driverConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+
+func (c driverConnector) Driver() driver.Driver { return c.d }
@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// ErrPeerCheckpointing is reported with WithProcessLock when a checkpoint is skipped because another process
+// holds the lock, it matches ErrCheckpointBusy too
+var ErrPeerCheckpointing = fmt.Errorf("%w, skipped: peer checkpointing", ErrCheckpointBusy)
+
+// processLockTimeout is how long a checkpoint waits for the lock of WithProcessLock
+const processLockTimeout = 100 * time.Millisecond
+
+// WithProcessLock takes an advisory lock on the file path (flock on Unix, LockFileEx on Windows) during
+// each checkpoint, so that the processes sharing a database do not checkpoint at the same time and
+// see each other as busy readers
+// An empty path is the path of the main database file followed by ".ckpt-lock", the file is created if needed
+// A checkpoint waits for the lock for up to 100ms, then it is skipped with ErrPeerCheckpointing,
+// see Stats.PeerSkippedCheckpoints
+// The operating system releases the lock of a process that crashed
+func WithProcessLock(path string) Option {
+	return func(c *Checkpointer) error {
+		if !processLockSupported {
+			return errors.New("sqlite: process locks are not supported on " + runtime.GOOS)
+		}
+		c.processLock, c.processLockPath = true, path
+		return nil
+	}
+}
+
+// openProcessLock opens the lock file of WithProcessLock
+func (c *Checkpointer) openProcessLock() error {
+	path := c.processLockPath
+	if path == "" {
+		db, err := dbPath(context.Background(), c.dbs[0].q, c.schema)
+		if err != nil {
+			return err
+		}
+		path = db + ".ckpt-lock"
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("sqlite: opening the process lock: %w", err)
+	}
+	c.lockFile = f
+	return nil
+}
+
+// lockProcess takes the lock of WithProcessLock, waiting for up to processLockTimeout
+func (c *Checkpointer) lockProcess(ctx context.Context) error {
+	deadline := time.Now().Add(processLockTimeout)
+	for {
+		ok, err := tryLockFile(c.lockFile)
+		if err != nil {
+			return fmt.Errorf("sqlite: locking %s: %w", c.lockFile.Name(), err)
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrPeerCheckpointing
+		}
+		t := time.NewTimer(5 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
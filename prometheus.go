@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationBuckets are the upper bounds of the buckets of the histogram of the checkpoint durations
+var durationBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+// histogram counts the checkpoint durations per bucket of durationBuckets, the last one being +Inf
+type histogram struct {
+	counts [9]uint64
+	sum    time.Duration
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(durationBuckets) && d > durationBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.sum += d
+}
+
+// WithName sets the value of the database label of the metrics written by WritePrometheus,
+// so that several Checkpointers can be told apart, Manager.Register sets it to the registered name
+func WithName(name string) Option {
+	return func(c *Checkpointer) error {
+		c.name = name
+		return nil
+	}
+}
+
+// WritePrometheus writes the statistics in the Prometheus text exposition format, for instance from an HTTP handler,
+// without depending on the Prometheus client library
+// It is not a prometheus.Collector: with the client library, serve it on its own path or wrap Stats in a collector
+// The metrics have a database label if WithName is given
+// sqlite_wal_bytes is left out for the databases whose size cannot be read, see Sizes
+func (c *Checkpointer) WritePrometheus(w io.Writer) error {
+	return writePrometheus(w, []*Checkpointer{c})
+}
+
+// WritePrometheus is like Checkpointer.WritePrometheus for every registered database,
+// the database label of each metric being the registered name
+func (m *Manager) WritePrometheus(w io.Writer) error {
+	var cs []*Checkpointer
+	for _, name := range m.Names() {
+		if c := m.Checkpointer(name); c != nil {
+			cs = append(cs, c)
+		}
+	}
+	return writePrometheus(w, cs)
+}
+
+var labelValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writePrometheus(w io.Writer, cs []*Checkpointer) error {
+	type sample struct {
+		suffix, labels string
+		value          float64
+	}
+	type family struct {
+		name, typ, help string
+		samples         []sample
+	}
+	families := []family{
+		{name: "sqlite_checkpoints_total", typ: "counter", help: "Number of checkpoints attempted."},
+		{name: "sqlite_checkpoint_failures_total", typ: "counter", help: "Number of checkpoints that returned an error."},
+		{name: "sqlite_checkpoint_busy_total", typ: "counter", help: "Number of checkpoints that could not complete."},
+		{name: "sqlite_wal_pages_reclaimed_total", typ: "counter", help: "Number of pages checkpointed."},
+		{name: "sqlite_last_checkpoint_age_seconds", typ: "gauge", help: "Time since the last successful checkpoint, -1 if none."},
+		{name: "sqlite_wal_pages", typ: "gauge", help: "Number of pages in the WAL after the last checkpoint."},
+		{name: "sqlite_wal_bytes", typ: "gauge", help: "Size of the WAL files."},
+		{name: "sqlite_checkpoint_duration_seconds", typ: "histogram", help: "Duration of the checkpoints."},
+	}
+	for _, c := range cs {
+		s, sizeErr := c.StatsWithSizes(context.Background())
+		c.lock()
+		h := c.hist
+		c.unlock()
+		age := -1.0
+		if !s.LastCheckpointAt.IsZero() {
+			age = time.Since(s.LastCheckpointAt).Seconds()
+		}
+		var label string
+		if c.name != "" {
+			label = `database="` + labelValue.Replace(c.name) + `"`
+		}
+		for i, v := range []float64{
+			float64(s.TotalCheckpoints),
+			float64(s.FailedCheckpoints),
+			float64(s.BusyCheckpoints),
+			float64(s.TotalWALPagesReclaimed),
+			age,
+			float64(s.WALPages),
+		} {
+			families[i].samples = append(families[i].samples, sample{"", label, v})
+		}
+		if sizeErr == nil {
+			wal := &families[len(families)-2]
+			wal.samples = append(wal.samples, sample{"", label, float64(s.WALBytes)})
+		}
+		hist := &families[len(families)-1]
+		var n uint64
+		for i, count := range h.counts {
+			n += count
+			le := "+Inf"
+			if i < len(durationBuckets) {
+				le = strconv.FormatFloat(durationBuckets[i].Seconds(), 'g', -1, 64)
+			}
+			hist.samples = append(hist.samples, sample{"_bucket", joinLabels(label, `le="`+le+`"`), float64(n)})
+		}
+		hist.samples = append(hist.samples, sample{"_sum", label, h.sum.Seconds()}, sample{"_count", label, float64(n)})
+	}
+	for _, f := range families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ); err != nil {
+			return err
+		}
+		for _, s := range f.samples {
+			labels := s.labels
+			if labels != "" {
+				labels = "{" + labels + "}"
+			}
+			if _, err := fmt.Fprintf(w, "%s%s%s %g\n", f.name, s.suffix, labels, s.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
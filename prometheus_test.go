@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	c := newTest(t, openTest(t, "test.db"), WithLimit(10), WithName("a\"b\\c\nd"))
+	c.lock()
+	c.stats.TotalCheckpoints = 6
+	c.stats.FailedCheckpoints = 1
+	c.stats.BusyCheckpoints = 2
+	c.stats.TotalWALPagesReclaimed = 1234
+	c.stats.WALPages = 5
+	// On both sides of the bounds of the buckets
+	for _, d := range []time.Duration{time.Millisecond, time.Millisecond + 1, 100 * time.Millisecond, 5 * time.Second, 6 * time.Second, time.Minute} {
+		c.hist.observe(d)
+	}
+	c.unlock()
+	_, wal, _, err := c.Sizes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := c.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP sqlite_checkpoints_total Number of checkpoints attempted.
+# TYPE sqlite_checkpoints_total counter
+sqlite_checkpoints_total{database="a\"b\\c\nd"} 6
+# HELP sqlite_checkpoint_failures_total Number of checkpoints that returned an error.
+# TYPE sqlite_checkpoint_failures_total counter
+sqlite_checkpoint_failures_total{database="a\"b\\c\nd"} 1
+# HELP sqlite_checkpoint_busy_total Number of checkpoints that could not complete.
+# TYPE sqlite_checkpoint_busy_total counter
+sqlite_checkpoint_busy_total{database="a\"b\\c\nd"} 2
+# HELP sqlite_wal_pages_reclaimed_total Number of pages checkpointed.
+# TYPE sqlite_wal_pages_reclaimed_total counter
+sqlite_wal_pages_reclaimed_total{database="a\"b\\c\nd"} 1234
+# HELP sqlite_last_checkpoint_age_seconds Time since the last successful checkpoint, -1 if none.
+# TYPE sqlite_last_checkpoint_age_seconds gauge
+sqlite_last_checkpoint_age_seconds{database="a\"b\\c\nd"} -1
+# HELP sqlite_wal_pages Number of pages in the WAL after the last checkpoint.
+# TYPE sqlite_wal_pages gauge
+sqlite_wal_pages{database="a\"b\\c\nd"} 5
+# HELP sqlite_wal_bytes Size of the WAL files.
+# TYPE sqlite_wal_bytes gauge
+sqlite_wal_bytes{database="a\"b\\c\nd"} ` + strconv.FormatInt(wal, 10) + `
+# HELP sqlite_checkpoint_duration_seconds Duration of the checkpoints.
+# TYPE sqlite_checkpoint_duration_seconds histogram
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="0.001"} 1
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="0.005"} 2
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="0.01"} 2
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="0.05"} 2
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="0.1"} 3
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="0.5"} 3
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="1"} 3
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="5"} 4
+sqlite_checkpoint_duration_seconds_bucket{database="a\"b\\c\nd",le="+Inf"} 6
+sqlite_checkpoint_duration_seconds_sum{database="a\"b\\c\nd"} 71.102000001
+sqlite_checkpoint_duration_seconds_count{database="a\"b\\c\nd"} 6
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithWriteQueue makes the Checkpointer run the functions given to Submit in a single goroutine,
+// batching up to size of them in a transaction committed at most latency after the first one is picked,
+// so that the writers of the process never contend for the database and share the syncs of the commits
+// Each transaction counts as one operation like with WithTx, Close runs the queued functions
+// before the final checkpoint
+// With NewMultiCheckpointer, the transactions are opened on the first database
+func WithWriteQueue(size int, latency time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if size <= 0 {
+			return fmt.Errorf("sqlite: invalid write queue batch size %d", size)
+		}
+		if latency < 0 {
+			return fmt.Errorf("sqlite: invalid write queue latency %v", latency)
+		}
+		c.queue = &writeQueue{
+			jobs:    make(chan *job, size),
+			size:    size,
+			latency: latency,
+			closing: make(chan struct{}),
+			done:    make(chan struct{}),
+		}
+		return nil
+	}
+}
+
+// errNoWriteQueue is returned by Submit without WithWriteQueue
+var errNoWriteQueue = errors.New("sqlite: no write queue, use WithWriteQueue")
+
+// Job states
+const (
+	jobQueued int32 = iota
+	jobStarted
+	jobCanceled
+)
+
+// job is a function given to Submit
+type job struct {
+	fn    func(*sql.Tx) error
+	state int32 // accessed atomically
+	err   chan error
+}
+
+// writeQueue holds the functions given to Submit until the goroutine of WithWriteQueue runs them
+type writeQueue struct {
+	jobs    chan *job
+	size    int
+	latency time.Duration
+	mu      sync.RWMutex // read-locked by the senders to jobs, locked to set closed
+	closed  bool
+	closing chan struct{} // closed by close, once no function can be queued
+	done    chan struct{} // closed once the goroutine returns
+}
+
+// Submit queues fn to run in a transaction shared with the other queued functions, see WithWriteQueue,
+// and returns its error, or the error of the commit
+// fn runs in a savepoint, so that its error or its panic rolls its changes back without failing
+// the transaction, the transaction is retried like with Retry if the database is busy, fn may run again
+// Submit returns ctx.Err() if ctx is done before fn starts, afterwards it waits for the commit,
+// and ErrClosed once Close is called
+func (c *Checkpointer) Submit(ctx context.Context, fn func(*sql.Tx) error) error {
+	q := c.queue
+	if q == nil {
+		return errNoWriteQueue
+	}
+	j := &job{fn: fn, err: make(chan error, 1)}
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrClosed
+	}
+	select {
+	case q.jobs <- j:
+		q.mu.RUnlock()
+	case <-ctx.Done():
+		q.mu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case err := <-j.err:
+		return err
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&j.state, jobQueued, jobCanceled) {
+			return ctx.Err()
+		}
+		return <-j.err
+	}
+}
+
+// close stops queuing and waits for the queued functions to run
+func (q *writeQueue) close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+	}
+	q.mu.Unlock()
+	<-q.done
+}
+
+// work runs the goroutine of WithWriteQueue
+func (q *writeQueue) work(c *Checkpointer) {
+	defer close(q.done)
+	t := time.NewTimer(q.latency)
+	defer t.Stop()
+	for {
+		var batch []*job
+		select {
+		case j := <-q.jobs:
+			batch = append(batch, j)
+		case <-q.closing:
+			// No function can be queued anymore, run the remaining ones
+			for {
+				batch = batch[:0]
+				for len(batch) < q.size && len(q.jobs) > 0 {
+					batch = append(batch, <-q.jobs)
+				}
+				if len(batch) == 0 {
+					return
+				}
+				c.runBatch(batch)
+			}
+		}
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		t.Reset(q.latency)
+	collect:
+		for len(batch) < q.size {
+			select {
+			case j := <-q.jobs:
+				batch = append(batch, j)
+			case <-t.C:
+				break collect
+			case <-q.closing:
+				break collect
+			}
+		}
+		c.runBatch(batch)
+	}
+}
+
+// runBatch runs the functions of batch that are not canceled in a transaction and sends their errors
+func (c *Checkpointer) runBatch(batch []*job) {
+	jobs := batch[:0:0]
+	for _, j := range batch {
+		if atomic.CompareAndSwapInt32(&j.state, jobQueued, jobStarted) {
+			jobs = append(jobs, j)
+		}
+	}
+	if len(jobs) == 0 {
+		return
+	}
+	errs := make([]error, len(jobs))
+	done, _ := c.CheckpointContext(context.Background())
+	err := retry(context.Background(), c.dbs[0].q, func(tx *sql.Tx) error {
+		for i, j := range jobs {
+			errs[i] = Savepoint(tx, "", func() (err error) {
+				defer func() {
+					if p := recover(); p != nil {
+						err = fmt.Errorf("sqlite: submitted function panicked: %v", p)
+					}
+				}()
+				return j.fn(tx)
+			})
+			if errs[i] != nil && IsBusyError(errs[i]) {
+				return errs[i]
+			}
+		}
+		return nil
+	}, defaultRetry)
+	done()
+	for i, j := range jobs {
+		if errs[i] == nil {
+			errs[i] = err
+		}
+		j.err <- errs[i]
+	}
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrReadOnly is returned by NewCheckpointer for the databases opened by OpenReadOnly
+// or with pragma query_only set, which cannot be checkpointed
+var ErrReadOnly = errors.New("sqlite: the database is read-only")
+
+// uriPath escapes the characters of a path that have a meaning in an URI filename
+var uriPath = strings.NewReplacer("%", "%25", "#", "%23")
+
+// Immutable tells OpenReadOnly that the database file cannot change, not even by another process,
+// so that SQLite reads it without taking any lock nor looking for a WAL file
+// A change of the file while it is open returns wrong results or errors
+func Immutable() OpenOption {
+	return func(o *openConfig) error {
+		o.immutable = true
+		return nil
+	}
+}
+
+// OpenReadOnly opens the database file at path in read-only mode (URI parameter mode=ro)
+// with pragma query_only set on every connection and checked like the other pragmas, see Open
+// The defaults are query_only = on and busy_timeout = 5000
+// The database cannot be checkpointed: NewCheckpointer returns ErrReadOnly
+func OpenReadOnly(path string, opts ...OpenOption) (*sql.DB, error) {
+	o := openConfig{readOnly: true, pragmas: []pragma{
+		{"query_only", "on"},
+		{"busy_timeout", "5000"},
+	}}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	return o.open(path)
+}
+
+// checkWritable returns ErrReadOnly if pragma query_only is set on a connection of db
+func checkWritable(db queryer) error {
+	var value string
+	if err := db.QueryRowContext(context.Background(), `pragma query_only`).Scan(&value); err != nil {
+		return err
+	}
+	queryOnly, err := parseBool(value)
+	if err != nil {
+		return err
+	}
+	if queryOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
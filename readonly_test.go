@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`create table t (v); insert into t values (1)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	for _, opts := range [][]OpenOption{nil, {Immutable()}} {
+		ro, err := OpenReadOnly(path, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		if err := ro.QueryRow(`select count(*) from t`).Scan(&n); err != nil || n != 1 {
+			t.Errorf("%d rows (%v), want 1", n, err)
+		}
+		// Several connections, all read-only
+		ro.SetMaxIdleConns(4)
+		for i := 0; i < 4; i++ {
+			if _, err := ro.Exec(`insert into t values (2)`); err == nil {
+				t.Error("write succeeded on the read-only database")
+			}
+		}
+		if _, err := NewCheckpointer(ro, WithLimit(10)); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("NewCheckpointer returned %v, want ErrReadOnly", err)
+		}
+		ro.Close()
+	}
+	if n := count(t, path); n != 1 {
+		t.Errorf("%d rows after the writes, want 1", n)
+	}
+}
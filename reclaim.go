@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLowReclaim is reported with WithMinReclaimPct once the checkpoints write back too few pages of the WAL,
+// typically because readers are in the way
+var ErrLowReclaim = errors.New("sqlite: checkpoints reclaim too little of the WAL, readers may be in the way")
+
+// PercentReclaimed returns the percentage of the pages of the WAL written back to the database,
+// 100 if the WAL is empty or the database is not in WAL mode
+func (r CheckpointResult) PercentReclaimed() float64 {
+	if r.WALPages <= 0 || r.CheckpointedPages < 0 {
+		return 100
+	}
+	return 100 * float64(r.CheckpointedPages) / float64(r.WALPages)
+}
+
+// WithMinReclaimPct reports ErrLowReclaim once the average of PercentReclaimed over the last window checkpoints
+// falls below pct, like the failures of the checkpoints, then again only after it went back above pct
+// The failed checkpoints are not counted, the busy ones are
+func WithMinReclaimPct(pct float64, window int) Option {
+	return func(c *Checkpointer) error {
+		if pct <= 0 || pct > 100 || window <= 0 {
+			return fmt.Errorf("sqlite: invalid reclaim threshold (%g%% over %d checkpoints)", pct, window)
+		}
+		c.minReclaim = pct
+		c.reclaims = make([]float64, 0, window)
+		return nil
+	}
+}
+
+// observeReclaim adds the result of a checkpoint to the window of WithMinReclaimPct,
+// the caller must hold the lock
+func (c *Checkpointer) observeReclaim(res CheckpointResult) {
+	if c.minReclaim == 0 {
+		return
+	}
+	if len(c.reclaims) < cap(c.reclaims) {
+		c.reclaims = append(c.reclaims, res.PercentReclaimed())
+	} else {
+		c.reclaims[c.reclaimNext] = res.PercentReclaimed()
+		c.reclaimNext = (c.reclaimNext + 1) % len(c.reclaims)
+	}
+	if len(c.reclaims) < cap(c.reclaims) {
+		return
+	}
+	var sum float64
+	for _, pct := range c.reclaims {
+		sum += pct
+	}
+	avg := sum / float64(len(c.reclaims))
+	low := avg < c.minReclaim
+	if low && !c.reclaimLow {
+		c.lowReclaim = fmt.Errorf("%w: %.1f%% of the pages on average over the last %d checkpoints, below %g%%",
+			ErrLowReclaim, avg, len(c.reclaims), c.minReclaim)
+	}
+	c.reclaimLow = low
+}
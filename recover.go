@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// Recover opens the database file at path with Open, for instance a copy taken while it was written,
+// so that SQLite replays its WAL file, then writes the WAL back into the database with a Truncate checkpoint
+// It returns the number of frames of the WAL, 0 if there was none
+// The transactions that were not committed when the files were copied are lost, the others are kept
+func Recover(path string, opts ...OpenOption) (frames int, err error) {
+	db, err := Open(path, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err2 := db.Close(); err == nil {
+			err = err2
+		}
+	}()
+	// Reading the schema replays the WAL
+	var tables int
+	if err := db.QueryRow(`select count(*) from sqlite_master`).Scan(&tables); err != nil {
+		return 0, fmt.Errorf("sqlite: recovering %s: %w", path, err)
+	}
+	// A Truncate checkpoint reports an empty WAL once it is truncated, a Passive one reports its frames first
+	for _, mode := range []CheckpointMode{Passive, Truncate} {
+		res, err := checkpoint(context.Background(), db, "", mode)
+		if err != nil {
+			return 0, fmt.Errorf("sqlite: recovering %s: %w", path, err)
+		}
+		if mode == Passive {
+			frames = max0(res.WALPages)
+		}
+	}
+	return frames, nil
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copyFile copies the file src to dst, if it exists
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	in, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	db, c, err := OpenWithCheckpointer(path, []Option{WithLimit(50)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	defer c.Close()
+	if _, err := db.Exec(`create table t (v)`); err != nil {
+		t.Fatal(err)
+	}
+	const committed = 120
+	for i := 0; i < committed; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	// Copy the files in the middle of a write, its guard outstanding and its transaction not committed
+	done := c.Checkpoint()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`insert into t values (1)`); err != nil {
+		t.Fatal(err)
+	}
+	copied := filepath.Join(t.TempDir(), "copy.db")
+	for _, suffix := range []string{"", "-wal"} {
+		copyFile(t, path+suffix, copied+suffix)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	done()
+	frames, err := Recover(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames == 0 {
+		t.Error("no frame recovered")
+	}
+	if n := count(t, copied); n != committed {
+		t.Errorf("%d rows in the recovered copy, want %d", n, committed)
+	}
+	if fi, err := os.Stat(copied + "-wal"); err == nil && fi.Size() > 0 {
+		t.Errorf("WAL of %d bytes after Recover", fi.Size())
+	}
+}
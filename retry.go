@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithBusyRetry retries the automatic checkpoints that are busy up to attempts times,
+// waiting delay before the first retry and twice as long before each next one
+// The retries run in a goroutine that does not block the writers while it waits,
+// only the outcome of the last attempt is reported
+// Flush and Force are not retried, they return ErrCheckpointBusy to the caller
+func WithBusyRetry(attempts int, delay time.Duration) Option {
+	return func(c *Checkpointer) error {
+		if attempts < 0 || delay <= 0 {
+			return fmt.Errorf("sqlite: invalid busy retry policy (%d attempts, %v delay)", attempts, delay)
+		}
+		c.retryDelays = make([]time.Duration, attempts)
+		for i := range c.retryDelays {
+			c.retryDelays[i] = delay << i
+		}
+		return nil
+	}
+}
+
+// WithBusyBackoff is like WithBusyRetry with explicit delays before each retry,
+// for instance 100ms, 500ms and 2s
+func WithBusyBackoff(delays ...time.Duration) Option {
+	return func(c *Checkpointer) error {
+		for _, d := range delays {
+			if d <= 0 {
+				return fmt.Errorf("sqlite: invalid busy retry delay %v", d)
+			}
+		}
+		c.retryDelays = append([]time.Duration(nil), delays...)
+		return nil
+	}
+}
+
+// outcome reports the failure of an automatic checkpoint or retries it if it was busy
+// The caller must not hold the lock
+func (c *Checkpointer) outcome(res CheckpointResult, err error) {
+	c.notify(res)
+	if err == nil {
+		return
+	}
+	if len(c.retryDelays) > 0 && isBusy(err) && c.startRetry() {
+		return
+	}
+	c.report(res, err)
+}
+
+// startRetry starts the retry goroutine, unless it is already running
+// It returns false if the Checkpointer is closed
+func (c *Checkpointer) startRetry() bool {
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return false
+	}
+	if !c.retrying {
+		c.retrying = true
+		c.bg.Add(1)
+		go c.retry()
+	}
+	return true
+}
+
+func (c *Checkpointer) retry() {
+	defer c.bg.Done()
+	var res CheckpointResult
+	var err error
+	for i, delay := range c.retryDelays {
+		t := time.NewTimer(delay)
+		select {
+		case <-c.stop:
+			t.Stop()
+			c.lock()
+			c.retrying = false
+			c.unlock()
+			return
+		case <-t.C:
+		}
+
+		c.lock()
+		c.stats.Retries++
+		if err = c.excludeAuto(context.Background()); err != nil {
+			c.skip()
+			c.retrying = false
+			c.unlock()
+			res = CheckpointResult{}
+			break
+		}
+		res, err = c.checkpoint(context.Background(), c.autoMode())
+		c.reset()
+		c.settle()
+		if !isBusy(err) || i == len(c.retryDelays)-1 {
+			c.retrying = false
+		}
+		c.unlock()
+		c.notify(res)
+		if !isBusy(err) {
+			break
+		}
+	}
+	if err != nil {
+		c.report(res, err)
+	}
+}
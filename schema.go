@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WithSchema restricts the checkpoints to the given schema, "main" or the name of an attached database,
+// which must be attached when the Checkpointer is created
+// By default, pragma wal_checkpoint checkpoints all the attached databases
+// The WAL size limit then applies to the WAL file of this schema instead of the main one
+// Attached databases belong to a connection, so the pool should have a single connection (see sql.DB.SetMaxOpenConns)
+// or every connection should attach them
+func WithSchema(name string) Option {
+	return func(c *Checkpointer) error {
+		if name == "" || strings.ContainsRune(name, 0) {
+			return fmt.Errorf("sqlite: invalid schema name %q", name)
+		}
+		if strings.EqualFold(name, "temp") {
+			return errors.New("sqlite: the temp schema cannot be in WAL mode")
+		}
+		c.schema = name
+		return nil
+	}
+}
+
+// checkSchema returns an error if schema is not attached to db
+func checkSchema(db queryer, schema string) error {
+	names, err := schemas(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if strings.EqualFold(name, schema) {
+			return nil
+		}
+	}
+	return fmt.Errorf("sqlite: schema %q is not attached", schema)
+}
+
+// quoteIdent quotes an SQL identifier so that it can be interpolated safely
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// connectionPragmas are the settings of the connection, which have no schema
+var connectionPragmas = map[string]bool{
+	"analysis_limit":     true,
+	"busy_timeout":       true,
+	"temp_store":         true,
+	"wal_autocheckpoint": true,
+}
+
+// schemaPragma returns the statement of the pragma name for the schema, or for the default schema
+// (all the attached databases for wal_checkpoint) if it is empty or if the pragma is a setting of the connection
+// It builds every pragma of a schema: the schema is quoted, and name is a keyword of this package, never an input
+func schemaPragma(schema, name string) string {
+	if schema == "" || connectionPragmas[name] {
+		return `pragma ` + name
+	}
+	return `pragma ` + quoteIdent(schema) + `.` + name
+}
+
+// checkpointPragma returns the wal_checkpoint pragma for the schema, or for all the attached databases if it is empty,
+// it fails if mode is not one of the modes
+func checkpointPragma(schema string, mode CheckpointMode) (string, error) {
+	if !mode.valid() {
+		return "", fmt.Errorf("sqlite: unknown checkpoint mode %d", int(mode))
+	}
+	return schemaPragma(schema, `wal_checkpoint(`+mode.String()+`)`), nil
+}
+
+// SchemaResult is the result of the checkpoint of a schema, see CheckpointSchemas
+type SchemaResult struct {
+	Database int    // index of the database, always 0 unless NewMultiCheckpointer is used
+	Schema   string // "main" or the name of an attached database
+	CheckpointResult
+}
+
+// CheckpointSchemas is like CheckpointNow but checkpoints main and each attached database separately
+// and returns the result of each of them
+// Each checkpoint is recorded like the other ones, in the statistics, the history and the health for instance,
+// so LastResult is the one of the last schema
+func (c *Checkpointer) CheckpointSchemas(ctx context.Context) ([]SchemaResult, error) {
+	if err := c.lockContext(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.exclude(ctx); err != nil {
+		c.settle()
+		c.unlock()
+		return nil, err
+	}
+	var (
+		results []SchemaResult
+		errs    multiError
+	)
+	for i, d := range c.dbs {
+		schemas, err := schemas(ctx, d.q)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("database %d: %w", i, err))
+			continue
+		}
+		for _, schema := range schemas {
+			sel := c.selection(false)
+			sel.only, sel.schema = d, schema
+			res, err := c.checkpointSelection(ctx, c.mode, sel)
+			results = append(results, SchemaResult{i, schema, res})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("schema %s: %w", schema, err))
+			}
+		}
+	}
+	c.settle()
+	c.unlock()
+	for _, res := range results {
+		c.notify(res.CheckpointResult)
+	}
+	return results, errs.err()
+}
+
+// schemas returns the names of main and the attached databases
+func schemas(ctx context.Context, db queryer) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `pragma database_list`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return nil, err
+		}
+		if name != "temp" {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaPragma(t *testing.T) {
+	for _, tt := range []struct {
+		schema, name, want string
+	}{
+		{"", "journal_size_limit", `pragma journal_size_limit`},
+		{"main", "journal_size_limit", `pragma "main".journal_size_limit`},
+		{"aux", "page_count", `pragma "aux".page_count`},
+		{`a"b`, "page_count", `pragma "a""b".page_count`},
+		{`x".y; drop table t; --`, "page_count", `pragma "x"".y; drop table t; --".page_count`},
+		{"aux", "busy_timeout", `pragma busy_timeout`},
+		{"aux", "wal_autocheckpoint", `pragma wal_autocheckpoint`},
+	} {
+		if got := schemaPragma(tt.schema, tt.name); got != tt.want {
+			t.Errorf("schemaPragma(%q, %q) = %s, want %s", tt.schema, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckpointPragma(t *testing.T) {
+	for _, schema := range []struct{ name, prefix string }{
+		{"", `pragma `},
+		{"main", `pragma "main".`},
+		{`a"b`, `pragma "a""b".`},
+	} {
+		for mode, keyword := range map[CheckpointMode]string{Passive: "passive", Full: "full", Restart: "restart", Truncate: "truncate"} {
+			got, err := checkpointPragma(schema.name, mode)
+			if want := schema.prefix + "wal_checkpoint(" + keyword + ")"; err != nil || got != want {
+				t.Errorf("checkpointPragma(%q, %v) = %s, %v, want %s", schema.name, mode, got, err, want)
+			}
+		}
+		for _, mode := range []CheckpointMode{0, Truncate + 1, -1} {
+			if got, err := checkpointPragma(schema.name, mode); err == nil {
+				t.Errorf("checkpointPragma(%q, %d) = %s, want an error", schema.name, int(mode), got)
+			}
+		}
+	}
+}
+
+func TestWithSchema(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ok   bool
+	}{
+		{"main", true},
+		{`a"b`, true},
+		{"", false},
+		{"a\x00b", false},
+		{"temp", false},
+		{"TEMP", false},
+	} {
+		var c Checkpointer
+		if err := WithSchema(tt.name)(&c); (err == nil) != tt.ok {
+			t.Errorf("WithSchema(%q): %v", tt.name, err)
+		}
+	}
+}
+
+func TestCheckpointSchemas(t *testing.T) {
+	db := openTest(t, "test.db")
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	aux := filepath.Join(t.TempDir(), "aux.db")
+	for _, query := range []string{
+		`attach database '` + aux + `' as aux`,
+		`pragma aux.journal_mode = wal`,
+		`create table aux.t (v)`,
+	} {
+		if _, err := conn.ExecContext(context.Background(), query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lock := filepath.Join(t.TempDir(), "lock")
+	c, err := NewCheckpointerConn(conn, WithLimit(1000), WithHistory(10), WithProcessLock(lock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, table := range []string{"main.t", "aux.t"} {
+		if _, err := conn.ExecContext(context.Background(), `insert into `+table+` values (1)`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := c.CheckpointSchemas(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Schema != "main" || results[1].Schema != "aux" {
+		t.Fatalf("results %+v", results)
+	}
+	for _, res := range results {
+		if res.WALPages <= 0 || res.CheckpointedPages != res.WALPages {
+			t.Errorf("%s: %d of %d pages", res.Schema, res.CheckpointedPages, res.WALPages)
+		}
+	}
+	// Each schema is recorded like the other checkpoints
+	if n := c.Stats().TotalCheckpoints; n != 2 {
+		t.Errorf("%d checkpoints in the statistics", n)
+	}
+	if n := len(c.History()); n != 2 {
+		t.Errorf("%d checkpoints in the history", n)
+	}
+	if res := c.LastResult(); res.Duration != results[1].Duration || res.WALPages != results[1].WALPages {
+		t.Errorf("LastResult %+v, want the one of aux", res)
+	}
+
+	// And waits for the lock of WithProcessLock
+	if !processLockSupported {
+		return
+	}
+	f, err := os.OpenFile(lock, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ok, err := tryLockFile(f); !ok || err != nil {
+		t.Fatalf("locking: %v, %v", ok, err)
+	}
+	results, err = c.CheckpointSchemas(context.Background())
+	if !errors.Is(err, ErrPeerCheckpointing) || len(results) != 2 {
+		t.Errorf("CheckpointSchemas with the lock held by a peer: %d results, %v", len(results), err)
+	}
+	if n := c.Stats().PeerSkippedCheckpoints; n != 2 {
+		t.Errorf("%d peer skipped checkpoints", n)
+	}
+	unlockFile(f)
+}
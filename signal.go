@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// RunUntilSignal blocks until one of sigs is received, os.Interrupt and SIGTERM if none is given,
+// then closes the Checkpointer, which waits for the calls in progress and performs a final Truncate checkpoint,
+// and returns the error of Close
+// If ctx is done first, it returns its error and leaves the Checkpointer open
+func (c *Checkpointer) RunUntilSignal(ctx context.Context, sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ch:
+		return c.Close()
+	}
+}
@@ -0,0 +1,101 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package sqlite
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunUntilSignalContext(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.RunUntilSignal(ctx); err != context.Canceled {
+		t.Fatalf("RunUntilSignal returned %v", err)
+	}
+	done, err := c.CheckpointContext(context.Background())
+	if err != nil {
+		t.Errorf("the Checkpointer is not left open: %v", err)
+	}
+	done()
+}
+
+func TestRunUntilSignal(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		sig    syscall.Signal
+		custom bool
+	}{
+		{"custom", syscall.SIGUSR1, true},
+		{"SIGTERM", syscall.SIGTERM, false},
+		{"Interrupt", syscall.SIGINT, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			runUntilSignal(t, tt.sig, tt.custom)
+		})
+	}
+}
+
+// runUntilSignal sends sig to the process until RunUntilSignal returns, giving it sig if custom
+func runUntilSignal(t *testing.T, sig syscall.Signal, custom bool) {
+	// Also caught by the test, so that the signals sent before RunUntilSignal listens do not kill the process
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, sig)
+	defer signal.Stop(caught)
+
+	db := openTest(t, "test.db")
+	var seq int
+	var schema, path string
+	if err := db.QueryRow(`pragma database_list`).Scan(&seq, &schema, &path); err != nil {
+		t.Fatal(err)
+	}
+	c := newTest(t, db, WithLimit(1000))
+	for i := 0; i < 10; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	if fi, err := os.Stat(path + "-wal"); err != nil || fi.Size() == 0 {
+		t.Fatalf("no WAL before the signal: %v", err)
+	}
+	var sigs []os.Signal
+	if custom {
+		sigs = []os.Signal{sig}
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- c.RunUntilSignal(context.Background(), sigs...) }()
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(5 * time.Second)
+	for returned := false; !returned; {
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Fatalf("RunUntilSignal returned %v", err)
+			}
+			returned = true
+		case <-tick.C:
+		case <-timeout:
+			t.Fatal("RunUntilSignal did not return")
+		}
+	}
+	// The final checkpoint of Close truncates the WAL
+	if fi, err := os.Stat(path + "-wal"); err != nil {
+		t.Error(err)
+	} else if fi.Size() != 0 {
+		t.Errorf("WAL of %d bytes after the signal", fi.Size())
+	}
+	if done, err := c.CheckpointContext(context.Background()); err != ErrClosed {
+		done()
+		t.Errorf("CheckpointContext after the signal returned %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,221 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// sizeCheckEvery is the number of calls to Checkpoint between two checks of the WAL file size
+const sizeCheckEvery = 64
+
+// NewSizeCheckPointer returns a checkpointer that checkpoints when the WAL file reaches size bytes,
+// see NewCheckpointer and WithWALSizeLimit
+//
+// Deprecated: use NewCheckpointer(db, WithWALSizeLimit(size), opts...)
+func NewSizeCheckPointer(db *sql.DB, size int64, opts ...Option) (*Checkpointer, error) {
+	return NewCheckpointer(db, append([]Option{WithWALSizeLimit(size)}, opts...)...)
+}
+
+// WithWALSizeLimit triggers a checkpoint when the WAL file reaches size bytes
+// The signal is the size of the "-wal" file next to the main database file (found with pragma database_list),
+// it is checked every 64 calls to Checkpoint, not on every call
+// It complements the limit: whichever is reached first triggers the checkpoint
+func WithWALSizeLimit(size int64) Option {
+	return func(c *Checkpointer) error {
+		if size <= 0 {
+			return fmt.Errorf("sqlite: invalid WAL size limit %d", size)
+		}
+		c.walLimit = size
+		return nil
+	}
+}
+
+// ErrNoFile is returned for in-memory and temporary databases, which have no file
+var ErrNoFile = errors.New("sqlite: the database has no file (in-memory or temporary database)")
+
+// walPath returns the path of the WAL file of the given schema, main if it is empty
+func walPath(db queryer, schema string) (string, error) {
+	path, err := dbPath(context.Background(), db, schema)
+	if err != nil {
+		return "", err
+	}
+	return path + "-wal", nil
+}
+
+// dbPath returns the path of the database file of the given schema, main if it is empty
+func dbPath(ctx context.Context, db queryer, schema string) (string, error) {
+	if schema == "" {
+		schema = "main"
+	}
+	rows, err := db.QueryContext(ctx, `pragma database_list`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(name, schema) {
+			continue
+		}
+		if file == "" {
+			return "", fmt.Errorf("%w: %s", ErrNoFile, name)
+		}
+		return file, rows.Close()
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("sqlite: %s database not found", schema)
+}
+
+// WithWALPageLimit triggers a checkpoint when the WAL holds more than pages pages
+// The signal is a passive checkpoint, which never waits for readers or writers, performed every 64 calls
+// to Checkpoint like the check of WithWALSizeLimit: the checkpoint follows only if it could not write back every page
+// It complements the limit, see also WithCountLimit: whichever is reached first triggers the checkpoint,
+// which starts a new count of the calls
+func WithWALPageLimit(pages int) Option {
+	return func(c *Checkpointer) error {
+		if pages <= 0 {
+			return fmt.Errorf("sqlite: invalid WAL page limit %d", pages)
+		}
+		c.pageLimit = pages
+		return nil
+	}
+}
+
+// WithCountLimit is WithLimit, for the code combining it with WithWALPageLimit
+func WithCountLimit(n uint) Option {
+	return WithLimit(n)
+}
+
+// walFull reports whether a WAL file reached the size or page limit, or the rows the row change limit, before a call weighing n when the counter is i,
+// it only checks the files when the call reaches a multiple of sizeCheckEvery, the caller must hold the lock
+func (c *Checkpointer) walFull(i, n uint64) (bool, error) {
+	if !c.sampled() || !sizeCheck(i, n) {
+		return false, nil
+	}
+	if c.rowLimit > 0 {
+		if over, err := c.rowsOver(); over || err != nil {
+			return over, err
+		}
+	}
+	if c.pageLimit > 0 {
+		if over, err := c.walOver(c.pageLimit); over || err != nil {
+			return over, err
+		}
+	}
+	if c.walLimit == 0 {
+		return false, nil
+	}
+	for _, d := range c.dbs {
+		fi, err := os.Stat(d.walPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("checking WAL size: %w", err)
+		}
+		if fi.Size() >= c.walLimit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// WithPostOpSizeCheck checks the size of the WAL files when every nth call to Checkpoint ends, against the limits
+// of WithWALSizeLimit and WithWALPageLimit, so that a large operation triggers a checkpoint on the next call
+// instead of the next periodic check of these options
+// The size limit is checked with the size of the files, the page limit with a passive checkpoint
+func WithPostOpSizeCheck(n int) Option {
+	return func(c *Checkpointer) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid post-operation size check period %d", n)
+		}
+		c.postOpEvery = uint64(n)
+		return nil
+	}
+}
+
+// postOp checks the size of the WAL files at the end of every nth call to Checkpoint, see WithPostOpSizeCheck,
+// the caller must not hold the lock
+func (c *Checkpointer) postOp() {
+	if c.postOpEvery == 0 || atomic.AddUint64(&c.postOps, 1)%c.postOpEvery != 0 || atomic.LoadInt32(&c.armed) != 0 {
+		return
+	}
+	over, err := c.walSizeOver()
+	if err != nil && !isBusy(err) {
+		c.report(CheckpointResult{}, err)
+	}
+	if over {
+		atomic.StoreInt32(&c.armed, 1)
+	}
+}
+
+// walSizeOver reports whether a WAL file reached the size limit or the page limit
+func (c *Checkpointer) walSizeOver() (bool, error) {
+	for _, d := range c.dbs {
+		if c.walLimit == 0 {
+			break
+		}
+		fi, err := os.Stat(d.walPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("checking WAL size: %w", err)
+		}
+		if fi.Size() >= c.walLimit {
+			return true, nil
+		}
+	}
+	if c.pageLimit > 0 {
+		return c.walOver(c.pageLimit)
+	}
+	return false, nil
+}
+
+// sampled reports whether a trigger is checked every sizeCheckEvery calls to Checkpoint
+func (c *Checkpointer) sampled() bool {
+	return c.walLimit > 0 || c.pageLimit > 0 || c.rowLimit > 0
+}
+
+// sizeCheck reports whether a call weighing n reaches a multiple of sizeCheckEvery when the counter is i
+func sizeCheck(i, n uint64) bool {
+	return i/sizeCheckEvery != (i+n)/sizeCheckEvery
+}
+
+// Sizes returns the sizes in bytes of the database, WAL and shared memory files, summed over the databases
+// (of the schema set by WithSchema), 0 for the files that do not exist yet
+// It returns ErrNoFile for in-memory and temporary databases
+func (c *Checkpointer) Sizes(ctx context.Context) (dbBytes, walBytes, shmBytes int64, err error) {
+	for _, d := range c.dbs {
+		path, err := dbPath(ctx, d.q, c.schema)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		for _, f := range []struct {
+			suffix string
+			size   *int64
+		}{{"", &dbBytes}, {"-wal", &walBytes}, {"-shm", &shmBytes}} {
+			fi, err := os.Stat(path + f.suffix)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			*f.size += fi.Size()
+		}
+	}
+	return dbBytes, walBytes, shmBytes, nil
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// Snapshot runs fn in a transaction of the database (the first one with NewMultiCheckpointer) that is always
+// rolled back, so that its reads see a consistent snapshot while the writes go on
+// Meanwhile, the automatic checkpoints are passive: a Restart or Truncate checkpoint would wait for
+// the snapshot to end, with the writers blocked, see Stats.DowngradedCheckpoints
+// The snapshot is not counted and does not wait for a checkpoint in progress
+func (c *Checkpointer) Snapshot(ctx context.Context, fn func(*sql.Tx) error) error {
+	atomic.AddInt32(&c.snapshots, 1)
+	defer atomic.AddInt32(&c.snapshots, -1)
+	tx, err := c.dbs[0].q.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+// autoMode returns the mode of an automatic checkpoint, see WithEscalation, WithMaxWALPagesBeforeForce and WithTruncateEvery,
+// Passive during a snapshot, and makes it skip the idle databases, see CheckpointFor, the caller must hold the lock
+func (c *Checkpointer) autoMode() CheckpointMode {
+	c.selective = true
+	mode := c.escalated()
+	capped := c.overCap()
+	if capped {
+		mode = Truncate
+	}
+	periodic := !capped && c.truncateDue()
+	if periodic {
+		mode = Truncate
+	}
+	if mode != Passive && atomic.LoadInt32(&c.snapshots) > 0 {
+		// The Truncate checkpoint of WithTruncateEvery stays due
+		c.stats.DowngradedCheckpoints++
+		return Passive
+	}
+	if capped {
+		c.stats.HardCapCheckpoints++
+	}
+	c.countTruncate(periodic)
+	return mode
+}
@@ -0,0 +1,70 @@
+package sqlitetest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xpetit/sqlite"
+)
+
+// CountingCheckpointer wraps a sqlite.CheckpointManager and counts the guards acquired and released
+type CountingCheckpointer struct {
+	sqlite.CheckpointManager
+	acquired int64 // accessed atomically
+	released int64 // accessed atomically
+}
+
+// NewCountingCheckpointer returns a CountingCheckpointer wrapping m, sqlite.Nop() if it is nil,
+// the test fails when it ends if a guard was not released exactly once
+func NewCountingCheckpointer(tb testing.TB, m sqlite.CheckpointManager) *CountingCheckpointer {
+	tb.Helper()
+	if m == nil {
+		m = sqlite.Nop()
+	}
+	c := &CountingCheckpointer{CheckpointManager: m}
+	tb.Cleanup(func() {
+		if acquired, released := c.Acquired(), c.Released(); acquired != released {
+			tb.Errorf("sqlitetest: %d guards acquired, %d released", acquired, released)
+		}
+	})
+	return c
+}
+
+// Acquired returns the number of guards acquired
+func (c *CountingCheckpointer) Acquired() int {
+	return int(atomic.LoadInt64(&c.acquired))
+}
+
+// Released returns the number of guards released, each returned function counting once
+func (c *CountingCheckpointer) Released() int {
+	return int(atomic.LoadInt64(&c.released))
+}
+
+func (c *CountingCheckpointer) Checkpoint() func() {
+	return c.count(c.CheckpointManager.Checkpoint())
+}
+
+func (c *CountingCheckpointer) CheckpointContext(ctx context.Context) (func(), error) {
+	done, err := c.CheckpointManager.CheckpointContext(ctx)
+	if err != nil {
+		return done, err
+	}
+	return c.count(done), nil
+}
+
+func (c *CountingCheckpointer) CheckpointN(n uint) func() {
+	return c.count(c.CheckpointManager.CheckpointN(n))
+}
+
+// count registers an acquired guard and returns done releasing it
+func (c *CountingCheckpointer) count(done func()) func() {
+	atomic.AddInt64(&c.acquired, 1)
+	var once int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&once, 0, 1) {
+			atomic.AddInt64(&c.released, 1)
+		}
+		done()
+	}
+}
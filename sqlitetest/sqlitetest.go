@@ -0,0 +1,42 @@
+// Package sqlitetest creates temporary SQLite databases in WAL mode for tests
+//
+// A driver must be registered by the test, for instance by importing modernc.org/sqlite
+package sqlitetest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/xpetit/sqlite"
+)
+
+// Open opens a new database in a temporary directory with sqlite.Open and the given options,
+// it is closed and removed when the test ends
+func Open(tb testing.TB, opts ...sqlite.OpenOption) *sql.DB {
+	tb.Helper()
+	db, err := sqlite.Open(filepath.Join(tb.TempDir(), "test.db"), opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// NewCheckpointer opens a database like sqlite.OpenWithCheckpointer and returns it with a Checkpointer configured by opts,
+// the Checkpointer is closed before the database when the test ends, failing the test if Close fails
+// Without options, the Checkpointer checkpoints every 1000 calls
+func NewCheckpointer(tb testing.TB, opts ...sqlite.Option) (*sql.DB, *sqlite.Checkpointer) {
+	tb.Helper()
+	db := Open(tb, sqlite.WithPragma("wal_autocheckpoint", "0"), sqlite.WithTxLock("immediate"))
+	c, err := sqlite.NewCheckpointer(db, append([]sqlite.Option{sqlite.WithLimit(1000)}, opts...)...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			tb.Error(err)
+		}
+	})
+	return db, c
+}
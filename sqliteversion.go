@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a version of the SQLite library, see Checkpointer.Version
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+}
+
+// less reports whether v is older than w
+func (v Version) less(w Version) bool {
+	if v.Major != w.Major {
+		return v.Major < w.Major
+	}
+	if v.Minor != w.Minor {
+		return v.Minor < w.Minor
+	}
+	return v.Patch < w.Patch
+}
+
+// parseVersion parses the result of sqlite_version(), such as 3.45.1
+func parseVersion(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 4 {
+		return Version{}, fmt.Errorf("sqlite: invalid SQLite version %q", s)
+	}
+	var v [3]int
+	for i := 0; i < len(parts) && i < 3; i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("sqlite: invalid SQLite version %q", s)
+		}
+		v[i] = n
+	}
+	return Version{v[0], v[1], v[2]}, nil
+}
+
+// requirements are the versions of SQLite introducing the features used by a Checkpointer
+var requirements = []struct {
+	feature string
+	version Version
+	used    func(*Checkpointer) bool
+}{
+	{"the Truncate checkpoints, performed by Close", Version{3, 8, 8}, func(*Checkpointer) bool { return true }},
+	{"pragma optimize, see WithOptimize and WithAnalysisLimit", Version{3, 18, 0}, func(c *Checkpointer) bool {
+		return c.optimizeEvery > 0 || c.analysisLimit > 0
+	}},
+	{"pragma analysis_limit, see WithAnalysisLimit", Version{3, 32, 0}, func(c *Checkpointer) bool { return c.analysisLimit > 0 }},
+}
+
+// checkVersion returns the version of the SQLite library of db and an error if it does not support
+// a feature used by the Checkpointer
+func (c *Checkpointer) checkVersion(db queryer) (Version, error) {
+	var s string
+	if err := db.QueryRowContext(context.Background(), `select sqlite_version()`).Scan(&s); err != nil {
+		return Version{}, err
+	}
+	v, err := parseVersion(s)
+	if err != nil {
+		return Version{}, err
+	}
+	for _, r := range requirements {
+		if v.less(r.version) && r.used(c) {
+			return v, fmt.Errorf("sqlite: SQLite %v does not support %s, %v or later is required", v, r.feature, r.version)
+		}
+	}
+	return v, nil
+}
+
+// Version returns the version of the SQLite library of the database, the first one with NewMultiCheckpointer,
+// as checked by NewCheckpointer
+func (c *Checkpointer) Version() Version {
+	return c.version
+}
@@ -0,0 +1,205 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the cumulative statistics of a Checkpointer
+type Stats struct {
+	TotalCheckpoints       uint64         `json:"total_checkpoints"`         // number of checkpoints attempted
+	FailedCheckpoints      uint64         `json:"failed_checkpoints"`        // number of checkpoints that returned an error
+	BusyCheckpoints        uint64         `json:"busy_checkpoints"`          // number of checkpoints that could not complete, see ErrCheckpointBusy
+	PeerSkippedCheckpoints uint64         `json:"peer_skipped_checkpoints"`  // number of checkpoints skipped because another process held the lock of WithProcessLock, counted in BusyCheckpoints too
+	Retries                uint64         `json:"retries"`                   // number of retries of busy checkpoints, see WithBusyRetry
+	ThrottledWrites        uint64         `json:"throttled_writes"`          // number of calls to Checkpoint blocked by WithBackpressure
+	DebouncedCheckpoints   uint64         `json:"debounced_checkpoints"`     // number of triggered checkpoints skipped by WithDebounce
+	DowngradedCheckpoints  uint64         `json:"downgraded_checkpoints"`    // number of automatic checkpoints performed in Passive mode because of Snapshot
+	HardCapCheckpoints     uint64         `json:"hard_cap_checkpoints"`      // number of automatic checkpoints performed in Truncate mode because of WithMaxWALPagesBeforeForce
+	NoProgressCheckpoints  int            `json:"no_progress_checkpoints"`   // number of checkpoints in a row that wrote back no page of a non-empty WAL, see ErrWALPinned
+	TruncateCheckpoints    uint64         `json:"truncate_checkpoints"`      // number of automatic checkpoints performed in Truncate mode because of WithTruncateEvery
+	TruncatedWALBytes      uint64         `json:"truncated_wal_bytes"`       // cumulative number of bytes the WAL files shrank by during these checkpoints
+	DryRunCheckpoints      uint64         `json:"dry_run_checkpoints"`       // number of checkpoints skipped by WithDryRun, counted in TotalCheckpoints too
+	PartialCheckpoints     uint64         `json:"partial_checkpoints"`       // number of successful checkpoints that did not write back every page, see CheckpointResult.Partial
+	ConsecutivePartial     int            `json:"consecutive_partial"`       // number of partial checkpoints in a row, see WithPartialPolicy
+	TotalDuration          time.Duration  `json:"total_duration"`            // cumulative duration of the checkpoints
+	LastDuration           time.Duration  `json:"last_duration"`             // duration of the last checkpoint
+	MaxDuration            time.Duration  `json:"max_duration"`              // longest duration of a checkpoint
+	TotalWait              time.Duration  `json:"total_wait"`                // cumulative time spent waiting for the calls in progress before the checkpoints
+	LastWait               time.Duration  `json:"last_wait"`                 // time spent waiting for the calls in progress before the last checkpoint
+	MaxWait                time.Duration  `json:"max_wait"`                  // longest time spent waiting for the calls in progress before a checkpoint
+	LastMode               CheckpointMode `json:"last_mode"`                 // mode of the last checkpoint, see WithAdaptiveMode
+	WALPages               int            `json:"wal_pages"`                 // number of pages in the WAL after the last checkpoint
+	CheckpointedPages      int            `json:"checkpointed_pages"`        // number of pages checkpointed by the last checkpoint
+	TotalWALPagesReclaimed uint64         `json:"total_wal_pages_reclaimed"` // cumulative number of pages checkpointed
+	LastCheckpointAt       time.Time      `json:"last_checkpoint_at"`        // start of the last successful checkpoint, zero if none
+	Vacuums                uint64         `json:"vacuums"`                   // number of incremental vacuums, see WithIncrementalVacuum
+	FreelistPagesBefore    int            `json:"freelist_pages_before"`     // number of free pages before the last incremental vacuum
+	FreelistPagesAfter     int            `json:"freelist_pages_after"`      // number of free pages after the last incremental vacuum
+	LastAnalyzeAt          time.Time      `json:"last_analyze_at"`           // end of the last analyze of WithAnalyze, zero if none
+	RowsChanged            int64          `json:"rows_changed"`              // number of rows changed since the last successful checkpoint when last sampled, see WithRowChangeLimit
+	DBBytes                int64          `json:"db_bytes"`                  // size of the database files, set by StatsWithSizes only, see Sizes
+	WALBytes               int64          `json:"wal_bytes"`                 // size of the WAL files, set by StatsWithSizes only
+	SHMBytes               int64          `json:"shm_bytes"`                 // size of the shared memory files, set by StatsWithSizes only
+	SkippedDatabases       uint64         `json:"skipped_databases"`         // number of databases left out of the automatic checkpoints, see CheckpointFor
+	DoubleReleases         uint64         `json:"double_releases"`           // number of calls to Checkpoint released more than once, always counted, see Lease.Release
+	LeakedGuards           uint64         `json:"leaked_guards"`             // number of Leases garbage collected without Release, see WithLeakDetection
+	HoldOpenHealthy        bool           `json:"hold_open_healthy"`         // the connections of WithHoldOpen are open
+}
+
+// MarshalJSON encodes the durations in nanoseconds along with strings such as "1.5ms" in the fields suffixed
+// by _string, and a zero LastCheckpointAt or LastAnalyzeAt as null
+func (s Stats) MarshalJSON() ([]byte, error) {
+	type stats Stats
+	var lastCheckpointAt, lastAnalyzeAt *time.Time
+	if !s.LastCheckpointAt.IsZero() {
+		lastCheckpointAt = &s.LastCheckpointAt
+	}
+	if !s.LastAnalyzeAt.IsZero() {
+		lastAnalyzeAt = &s.LastAnalyzeAt
+	}
+	return json.Marshal(struct {
+		stats
+		LastCheckpointAt    *time.Time `json:"last_checkpoint_at"`
+		LastAnalyzeAt       *time.Time `json:"last_analyze_at"`
+		TotalDurationString string     `json:"total_duration_string"`
+		LastDurationString  string     `json:"last_duration_string"`
+		MaxDurationString   string     `json:"max_duration_string"`
+		TotalWaitString     string     `json:"total_wait_string"`
+		LastWaitString      string     `json:"last_wait_string"`
+		MaxWaitString       string     `json:"max_wait_string"`
+	}{
+		stats(s),
+		lastCheckpointAt,
+		lastAnalyzeAt,
+		s.TotalDuration.String(),
+		s.LastDuration.String(),
+		s.MaxDuration.String(),
+		s.TotalWait.String(),
+		s.LastWait.String(),
+		s.MaxWait.String(),
+	})
+}
+
+// String summarizes the statistics on one line, for instance:
+// 12 checkpoints (1 failed, 2 busy), last 1.5ms, 34 WAL pages, last success 3s ago
+func (s Stats) String() string {
+	if s.TotalCheckpoints == 0 {
+		return "no checkpoint yet"
+	}
+	last := "no success yet"
+	if !s.LastCheckpointAt.IsZero() {
+		last = "last success " + time.Since(s.LastCheckpointAt).Round(time.Millisecond).String() + " ago"
+	}
+	return fmt.Sprintf("%d checkpoints (%d failed, %d busy), last %v, %d WAL pages, %s",
+		s.TotalCheckpoints, s.FailedCheckpoints, s.BusyCheckpoints, s.LastDuration, s.WALPages, last)
+}
+
+// Stats returns a copy of the statistics, without the sizes of the files, see StatsWithSizes
+func (c *Checkpointer) Stats() Stats {
+	c.lock()
+	s := c.stats
+	c.unlock()
+	s.DoubleReleases = atomic.LoadUint64(&c.doubleReleases)
+	s.LeakedGuards = atomic.LoadUint64(&c.leaks)
+	return s
+}
+
+// StatsWithSizes is like Stats with the current sizes of the files, which take a query and a few system calls
+// per database to read, see Sizes
+// If they cannot be read, it returns the statistics with zero sizes and the error of Sizes
+func (c *Checkpointer) StatsWithSizes(ctx context.Context) (Stats, error) {
+	s := c.Stats()
+	var err error
+	s.DBBytes, s.WALBytes, s.SHMBytes, err = c.Sizes(ctx)
+	return s, err
+}
+
+// Reset zeroes the cumulative statistics, the histogram of WritePrometheus and the number of calls to
+// Checkpoint counted towards the limit, for instance at the start of a reporting window
+// The fields describing the last checkpoint and the ongoing streaks are kept, like the calls in progress
+// and the configuration, Reset does not checkpoint
+func (c *Checkpointer) Reset() {
+	c.lock()
+	defer c.unlock()
+	s := c.stats
+	c.stats = Stats{
+		NoProgressCheckpoints: s.NoProgressCheckpoints,
+		ConsecutivePartial:    s.ConsecutivePartial,
+		LastDuration:          s.LastDuration,
+		LastWait:              s.LastWait,
+		LastMode:              s.LastMode,
+		WALPages:              s.WALPages,
+		CheckpointedPages:     s.CheckpointedPages,
+		LastCheckpointAt:      s.LastCheckpointAt,
+		FreelistPagesBefore:   s.FreelistPagesBefore,
+		FreelistPagesAfter:    s.FreelistPagesAfter,
+		LastAnalyzeAt:         s.LastAnalyzeAt,
+		RowsChanged:           s.RowsChanged,
+		HoldOpenHealthy:       s.HoldOpenHealthy,
+	}
+	c.hist = histogram{}
+	atomic.StoreUint64(&c.doubleReleases, 0)
+	atomic.StoreUint64(&c.leaks, 0)
+	c.written += atomic.SwapUint64(&c.i, 0)
+	c.publish()
+}
+
+// record updates the statistics after a checkpoint started at start, the caller must hold the lock
+func (c *Checkpointer) record(start time.Time, res CheckpointResult, err error) {
+	defer c.publish()
+	d := res.Duration
+	c.stats.TotalCheckpoints++
+	c.stats.LastMode = res.Mode
+	if c.dryRun {
+		c.stats.DryRunCheckpoints++
+	}
+	c.stats.TotalDuration += d
+	c.hist.observe(d)
+	c.stats.LastDuration = d
+	if d > c.stats.MaxDuration {
+		c.stats.MaxDuration = d
+	}
+	c.stats.TotalWait += res.Wait
+	c.stats.LastWait = res.Wait
+	if res.Wait > c.stats.MaxWait {
+		c.stats.MaxWait = res.Wait
+	}
+	switch {
+	case isBusy(err):
+		c.stats.BusyCheckpoints++
+		if errors.Is(err, ErrPeerCheckpointing) {
+			c.stats.PeerSkippedCheckpoints++
+		}
+	case err != nil:
+		c.stats.FailedCheckpoints++
+		return
+	default:
+		c.stats.LastCheckpointAt = start
+		if res.Partial() {
+			c.stats.PartialCheckpoints++
+		}
+	}
+	c.stats.WALPages = res.WALPages
+	c.stats.CheckpointedPages = res.CheckpointedPages
+	if res.CheckpointedPages > 0 {
+		c.stats.TotalWALPagesReclaimed += uint64(res.CheckpointedPages)
+	}
+}
+
+// isBusy reports whether err only consists of ErrCheckpointBusy
+func isBusy(err error) bool {
+	if errs, ok := err.(multiError); ok {
+		for _, err := range errs {
+			if !isBusy(err) {
+				return false
+			}
+		}
+		return true
+	}
+	return errors.Is(err, ErrCheckpointBusy)
+}
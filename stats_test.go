@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStatsWithSizes(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(10))
+	for i := 0; i < 20; i++ {
+		done := c.Checkpoint()
+		insert(t, db)
+		done()
+	}
+	if s := c.Stats(); s.DBBytes != 0 || s.WALBytes != 0 {
+		t.Errorf("Stats read the sizes: %d, %d", s.DBBytes, s.WALBytes)
+	}
+	s, err := c.StatsWithSizes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.DBBytes == 0 || s.TotalCheckpoints == 0 {
+		t.Errorf("DBBytes = %d, TotalCheckpoints = %d", s.DBBytes, s.TotalCheckpoints)
+	}
+}
+
+func TestStatsWithSizesError(t *testing.T) {
+	db := openTest(t, "test.db")
+	c := newTest(t, db, WithLimit(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.StatsWithSizes(ctx); err == nil {
+		t.Error("no error with a canceled context")
+	}
+}
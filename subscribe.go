@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"sync"
+	"time"
+)
+
+// CheckpointEvent is sent to the channels of Subscribe after every checkpoint
+type CheckpointEvent struct {
+	Time   time.Time // end of the checkpoint
+	Mode   CheckpointMode
+	Result CheckpointResult
+	Err    error
+}
+
+// subscribers are the channels returned by Subscribe
+type subscribers struct {
+	mu     sync.Mutex
+	chs    []chan CheckpointEvent
+	closed bool
+}
+
+// subscriberBuffer is the number of events a subscriber can lag behind before missing some
+const subscriberBuffer = 16
+
+// Subscribe returns a channel receiving an event after every checkpoint, whatever triggered it
+// The channel buffers 16 events, the events that do not fit are dropped so that a slow receiver never blocks
+// the checkpoints, and it is closed by Close
+func (c *Checkpointer) Subscribe() <-chan CheckpointEvent {
+	ch := make(chan CheckpointEvent, subscriberBuffer)
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+	if c.subs.closed {
+		close(ch)
+		return ch
+	}
+	c.subs.chs = append(c.subs.chs, ch)
+	return ch
+}
+
+// publish sends the event of a checkpoint to the subscribers
+func (s *subscribers) publish(res CheckpointResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.chs) == 0 {
+		return
+	}
+	e := CheckpointEvent{Time: time.Now(), Mode: res.Mode, Result: res, Err: res.Err}
+	for _, ch := range s.chs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// close closes the channels of the subscribers
+func (s *subscribers) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.chs {
+		close(ch)
+	}
+	s.chs, s.closed = nil, true
+}
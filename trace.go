@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are the tracing hooks of WithTraceHooks, each of them is optional
+type Hooks struct {
+	// OnCheckpointStart is called right before every checkpoint with the context of the checkpoint,
+	// the context it returns is the one of the checkpoint, for instance with a span started from it
+	OnCheckpointStart func(ctx context.Context) context.Context
+	// OnCheckpointEnd is called after every checkpoint and its maintenance with the context returned
+	// by OnCheckpointStart and the result, with the mode, the duration, the wait and the error
+	OnCheckpointEnd func(ctx context.Context, res CheckpointResult)
+	// OnWriterBlocked is called after the calls to Checkpoint that did not return at once, with how long
+	// they were blocked, by a checkpoint in progress, by their own checkpoint or by WithBackpressure
+	OnWriterBlocked func(d time.Duration)
+}
+
+// WithTraceHooks sets the hooks for tracing the checkpoints, for instance with OpenTelemetry spans
+// OnCheckpointStart and OnCheckpointEnd are called with the lock held and writers blocked,
+// so they must be quick and must not use the Checkpointer
+func WithTraceHooks(h Hooks) Option {
+	return func(c *Checkpointer) error {
+		c.trace = h
+		return nil
+	}
+}
+
+// startTrace calls OnCheckpointStart and returns the context of the checkpoint
+func (c *Checkpointer) startTrace(ctx context.Context) context.Context {
+	if c.trace.OnCheckpointStart == nil {
+		return ctx
+	}
+	if tctx := c.trace.OnCheckpointStart(ctx); tctx != nil {
+		return tctx
+	}
+	return ctx
+}
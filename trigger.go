@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// TriggerState is the state given to the function set by WithTrigger
+type TriggerState struct {
+	Count           uint64        // weight of the calls since the last checkpoint, including the current one
+	Limit           uint          // see WithLimit and SetLimit
+	SinceCheckpoint time.Duration // time since the end of the last checkpoint, or since NewCheckpointer
+	WALPages        int           // number of pages in the WAL after the last checkpoint, -1 if none
+}
+
+// WithTrigger sets a function consulted by every call to Checkpoint in addition to the other triggers:
+// if it returns true, the call waits for the other calls in progress and performs a checkpoint
+// It is called concurrently and before the lock is taken, so it must be quick and must not use the Checkpointer
+func WithTrigger(f func(TriggerState) bool) Option {
+	return func(c *Checkpointer) error {
+		if f == nil {
+			return errors.New("sqlite: nil trigger")
+		}
+		c.trigger = f
+		return nil
+	}
+}
+
+// triggered consults the function set by WithTrigger for a call bringing the counter to i
+func (c *Checkpointer) triggered(i uint64) bool {
+	if c.trigger == nil {
+		return false
+	}
+	return c.trigger(TriggerState{
+		Count:           i,
+		Limit:           uint(atomic.LoadUint64(&c.limit)),
+		SinceCheckpoint: c.since(time.Unix(0, atomic.LoadInt64(&c.lastEnd))),
+		WALPages:        int(atomic.LoadInt64(&c.lastPages)),
+	})
+}
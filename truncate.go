@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+)
+
+// WithTruncateEvery makes every nth automatic checkpoint use the Truncate mode instead of the configured one,
+// so that the WAL files grown by a burst of writes shrink back, see Stats.TruncateCheckpoints
+// If the readers keep the Truncate checkpoint from completing, it falls back to a checkpoint in the configured
+// mode whose outcome is reported instead, the next Truncate checkpoint is still n automatic checkpoints later
+// See Diagnostics.UntilTruncate
+func WithTruncateEvery(n uint) Option {
+	return func(c *Checkpointer) error {
+		if n == 0 {
+			return errors.New("sqlite: invalid truncate period 0")
+		}
+		c.truncateEvery, c.untilTruncate = n, n
+		return nil
+	}
+}
+
+// truncateDue reports whether the next automatic checkpoint is the nth one of WithTruncateEvery
+func (c *Checkpointer) truncateDue() bool {
+	return c.truncateEvery > 0 && c.untilTruncate <= 1
+}
+
+// countTruncate counts an automatic checkpoint for WithTruncateEvery, periodic tells whether it is the nth one,
+// the caller must hold the lock
+func (c *Checkpointer) countTruncate(periodic bool) {
+	if c.truncateEvery == 0 {
+		return
+	}
+	c.truncating = periodic
+	if periodic {
+		c.untilTruncate = c.truncateEvery
+	} else {
+		c.untilTruncate--
+	}
+}
+
+// truncate performs the checkpoint of WithTruncateEvery, falling back to the configured mode if it is busy,
+// and records how much the WAL files shrank, the caller must hold the lock
+func (c *Checkpointer) truncate(ctx context.Context, sel selection) (CheckpointResult, error) {
+	_, before, _, sizeErr := c.Sizes(ctx)
+	res, err := c.run(ctx, Truncate, sel)
+	if isBusy(err) && c.mode != Truncate {
+		d := res.Duration
+		res, err = c.run(ctx, c.mode, sel)
+		res.Duration += d
+	}
+	c.stats.TruncateCheckpoints++
+	if _, after, _, err := c.Sizes(ctx); sizeErr == nil && err == nil && after < before {
+		c.stats.TruncatedWALBytes += uint64(before - after)
+	}
+	return res, err
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDeferredTx is returned by Retry and WithTx when the transactions of the database are not immediate:
+// a deferred transaction that read before another connection wrote fails with a busy error when upgrading
+// to a write, whatever the busy timeout, since its snapshot is stale, so the retries of concurrent writers keep
+// failing each other and the writes of WithTx fail in the middle of the callback
+// Open the database with WithTxLock("immediate"), the default of OpenWithCheckpointer
+var ErrDeferredTx = errors.New(`sqlite: the transactions are not immediate, open the database with WithTxLock("immediate")`)
+
+// RetryOption configures Retry
+type RetryOption func(*retryConfig) error
+
+type retryConfig struct {
+	attempts   int
+	delay, max time.Duration
+}
+
+var defaultRetry = retryConfig{attempts: 10, delay: 10 * time.Millisecond, max: time.Second}
+
+// WithAttempts sets the maximum number of attempts of Retry, the default is 10
+func WithAttempts(n int) RetryOption {
+	return func(r *retryConfig) error {
+		if n <= 0 {
+			return fmt.Errorf("sqlite: invalid number of attempts %d", n)
+		}
+		r.attempts = n
+		return nil
+	}
+}
+
+// WithBackoff sets the delay of Retry before the first retry, doubled before each next one up to max,
+// the defaults are 10ms and 1s
+// A random jitter of up to half the delay is subtracted so that competing writers do not retry together
+func WithBackoff(delay, max time.Duration) RetryOption {
+	return func(r *retryConfig) error {
+		if delay <= 0 || max < delay {
+			return fmt.Errorf("sqlite: invalid backoff (%v delay, %v max)", delay, max)
+		}
+		r.delay, r.max = delay, max
+		return nil
+	}
+}
+
+// Retry runs fn in an immediate transaction, committed if fn returns nil, and runs it again in a new transaction
+// while it fails because the database is busy or locked (see IsBusyError), until ctx is done
+// Other errors are returned immediately
+// The database must be opened by Open with WithTxLock("immediate"), which sets the _txlock DSN parameter,
+// so that the transactions wait for the write lock when they begin, Retry returns ErrDeferredTx otherwise
+func Retry(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error, opts ...RetryOption) error {
+	r := defaultRetry
+	for _, opt := range opts {
+		if err := opt(&r); err != nil {
+			return err
+		}
+	}
+	if !immediate(db) {
+		return ErrDeferredTx
+	}
+	return retry(ctx, db, fn, r)
+}
+
+// retry is Retry on a database or a connection
+func retry(ctx context.Context, db handle, fn func(*sql.Tx) error, r retryConfig) error {
+	return retryBusy(ctx, func() error { return runTx(ctx, db, fn) }, r)
+}
+
+// retryBusy calls fn again while it fails because the database is busy or locked, like Retry
+func retryBusy(ctx context.Context, fn func() error, r retryConfig) error {
+	delay := r.delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsBusyError(err) || attempt == r.attempts {
+			return err
+		}
+		t := time.NewTimer(delay - time.Duration(rand.Int63n(int64(delay/2)+1)))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		if delay *= 2; delay > r.max {
+			delay = r.max
+		}
+	}
+}
+
+// runTx runs fn in a transaction, committed if fn returns nil and rolled back otherwise or if fn panics
+func runTx(ctx context.Context, db handle, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// savepoints numbers the savepoints named by Savepoint, accessed atomically
+var savepoints uint64
+
+// Savepoint runs fn in a savepoint of tx: it is released if fn returns nil, otherwise or if fn panics,
+// the changes made since the savepoint are rolled back and the transaction goes on
+// Savepoints can be nested, an empty name generates a unique one, other names are quoted
+// It is intended to be used in the function given to WithTx:
+//
+//	c.WithTx(ctx, func(tx *sql.Tx) error {
+//		insertOrder(tx)
+//		if err := sqlite.Savepoint(tx, "", func() error { return insertOptionalDetails(tx) }); err != nil {
+//			log.Println("details skipped:", err)
+//		}
+//		return nil
+//	})
+func Savepoint(tx *sql.Tx, name string, fn func() error) (err error) {
+	if strings.ContainsRune(name, 0) {
+		return fmt.Errorf("sqlite: invalid savepoint name %q", name)
+	}
+	if name == "" {
+		name = "sqlite_" + strconv.FormatUint(atomic.AddUint64(&savepoints, 1), 10)
+	}
+	name = quoteIdent(name)
+	if _, err := tx.Exec(`savepoint ` + name); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollbackTo(tx, name)
+			panic(p)
+		}
+	}()
+	if err := fn(); err != nil {
+		if err2 := rollbackTo(tx, name); err2 != nil {
+			return fmt.Errorf("%w (rolling back to the savepoint: %v)", err, err2)
+		}
+		return err
+	}
+	_, err = tx.Exec(`release ` + name)
+	return err
+}
+
+// rollbackTo rolls back to the quoted savepoint name and releases it
+func rollbackTo(tx *sql.Tx, name string) error {
+	if _, err := tx.Exec(`rollback to ` + name); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`release ` + name)
+	return err
+}
+
+// IsBusyError reports whether err is a busy or locked error of SQLite (SQLITE_BUSY or SQLITE_LOCKED),
+// from modernc.org/sqlite, github.com/mattn/go-sqlite3 or ErrCheckpointBusy
+func IsBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCheckpointBusy) {
+		return true
+	}
+	// modernc.org/sqlite
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) {
+		switch coder.Code() & 0xff {
+		case 5, 6:
+			return true
+		}
+	}
+	// github.com/mattn/go-sqlite3 has no method giving the code
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked") ||
+		strings.Contains(msg, "SQLITE_BUSY") ||
+		strings.Contains(msg, "SQLITE_LOCKED")
+}
@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRetryImmediate(t *testing.T) {
+	db := openTest(t, "test.db")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				// Read then write, which fails when upgrading a deferred transaction
+				if err := Retry(context.Background(), db, func(tx *sql.Tx) error {
+					var n int
+					if err := tx.QueryRow(`select count(*) from t`).Scan(&n); err != nil {
+						return err
+					}
+					_, err := tx.Exec(`insert into t values (?)`, n)
+					return err
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	var n, distinct int
+	if err := db.QueryRow(`select count(*), count(distinct v) from t`).Scan(&n, &distinct); err != nil {
+		t.Fatal(err)
+	}
+	if n != 160 || distinct != 160 {
+		t.Errorf("%d rows, %d distinct, want 160", n, distinct)
+	}
+}
+
+func TestRetryDeferred(t *testing.T) {
+	for _, opts := range [][]OpenOption{nil, {WithTxLock("deferred")}} {
+		db, err := Open(filepath.Join(t.TempDir(), "test.db"), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Retry(context.Background(), db, func(*sql.Tx) error { return nil }); !errors.Is(err, ErrDeferredTx) {
+			t.Errorf("Retry with %d options returned %v", len(opts), err)
+		}
+		db.Close()
+	}
+}
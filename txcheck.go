@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrOpenTransaction is reported with WithTxCheck when a checkpoint fails because its connection has
+// an open transaction, for instance left by db.Exec("begin") on the pool
+var ErrOpenTransaction = errors.New("sqlite: checkpoint attempted with an open transaction on its connection")
+
+// WithTxCheck checks the connection of the checkpoints that fail for an open transaction, which makes them fail
+// with a locked or busy error that does not tell why, and reports ErrOpenTransaction instead
+// The checks are only performed after the failures, the checkpoints then use a connection taken from the pool
+// unless WithDedicatedConn is given
+func WithTxCheck() Option {
+	return func(c *Checkpointer) error {
+		c.txCheck = true
+		return nil
+	}
+}
+
+// checkTx returns err wrapped with ErrOpenTransaction if the connection q has an open transaction,
+// err otherwise
+func checkTx(ctx context.Context, q queryer, err error) error {
+	// Beginning a transaction is the only way to tell with SQL
+	_, err2 := q.ExecContext(ctx, `begin`)
+	if err2 == nil {
+		q.ExecContext(ctx, `rollback`)
+		return err
+	}
+	if strings.Contains(err2.Error(), "within a transaction") {
+		return fmt.Errorf("%w: %v", ErrOpenTransaction, err)
+	}
+	return err
+}
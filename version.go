@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// UserVersion returns pragma user_version, the version of the schema of the application, see Migrate
+func UserVersion(ctx context.Context, e Execer) (int32, error) {
+	return intPragma(ctx, e, "user_version")
+}
+
+// SetUserVersion sets pragma user_version, in the transaction if e is a *sql.Tx
+func SetUserVersion(ctx context.Context, e Execer, v int32) error {
+	return setIntPragma(ctx, e, "user_version", v)
+}
+
+// ApplicationID returns pragma application_id, which identifies the file format of the application
+func ApplicationID(ctx context.Context, e Execer) (int32, error) {
+	return intPragma(ctx, e, "application_id")
+}
+
+// SetApplicationID sets pragma application_id, in the transaction if e is a *sql.Tx
+func SetApplicationID(ctx context.Context, e Execer, id int32) error {
+	return setIntPragma(ctx, e, "application_id", id)
+}
+
+func intPragma(ctx context.Context, e Execer, name string) (int32, error) {
+	var v int32
+	if err := e.QueryRowContext(ctx, `pragma `+name).Scan(&v); err != nil {
+		return 0, fmt.Errorf("sqlite: reading pragma %s: %w", name, err)
+	}
+	return v, nil
+}
+
+func setIntPragma(ctx context.Context, e Execer, name string, v int32) error {
+	// The pragmas take no parameters
+	if _, err := e.ExecContext(ctx, `pragma `+name+` = `+strconv.FormatInt(int64(v), 10)); err != nil {
+		return fmt.Errorf("sqlite: setting pragma %s: %w", name, err)
+	}
+	return nil
+}
+
+// Migrate runs the migrations that are not applied yet, user_version being the number of migrations applied:
+// each of them in its own transaction, which also sets user_version, so that a failed migration is not applied
+// and the next call starts from it
+// The version is read again in each transaction, which is retried while the database is busy like with Retry,
+// so that the processes migrating the same database concurrently apply each migration once
+// It fails if user_version is above the number of migrations, the database being newer than the application
+func Migrate(ctx context.Context, db *sql.DB, migrations []func(*sql.Tx) error) error {
+	_, err := migrate(ctx, db, migrations)
+	return err
+}
+
+// migrate is Migrate on a database or a connection, it returns the number of migrations applied
+func migrate(ctx context.Context, db handle, migrations []func(*sql.Tx) error) (applied int, err error) {
+	for i, migration := range migrations {
+		var done bool
+		err := retry(ctx, db, func(tx *sql.Tx) error {
+			done = false
+			v, err := UserVersion(ctx, tx)
+			if err != nil {
+				return err
+			}
+			switch {
+			case int(v) > len(migrations):
+				return fmt.Errorf("sqlite: the database version %d is newer than the %d migrations", v, len(migrations))
+			case int(v) > i:
+				return nil
+			case int(v) < i:
+				return fmt.Errorf("sqlite: the database version %d went back before migration %d", v, i+1)
+			}
+			if err := migration(tx); err != nil {
+				return fmt.Errorf("sqlite: migration %d: %w", i+1, err)
+			}
+			done = true
+			return SetUserVersion(ctx, tx, int32(i+1))
+		}, defaultRetry)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// Migrate is like the Migrate function for the database of the Checkpointer (the first one with NewMultiCheckpointer),
+// followed by a Truncate checkpoint if migrations were applied, so that they do not leave a large WAL behind
+func (c *Checkpointer) Migrate(ctx context.Context, migrations []func(*sql.Tx) error) error {
+	applied, err := migrate(ctx, c.dbs[0].q, migrations)
+	if applied > 0 {
+		if _, err2 := c.force(ctx, Truncate); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WithEnableWAL sets the journal mode of the databases to WAL if needed, instead of failing
+// The WAL journal mode is persistent, it applies to every connection
+func WithEnableWAL() Option {
+	return func(c *Checkpointer) error {
+		c.enableWAL = true
+		return nil
+	}
+}
+
+// errInMemory is returned for in-memory databases, whose journal cannot be in WAL mode
+var errInMemory = errors.New("sqlite: in-memory databases cannot use the WAL journal mode, a file is required")
+
+// WithWAL2 sets the journal mode of the databases to WAL2 if the SQLite build supports it, falling back to WAL
+// (see WithEnableWAL) otherwise
+// WAL2 alternates between two WAL files, so the writers never prevent restarting them and the checkpoints
+// are passive by default, see JournalMode
+// Databases in WAL2 mode cannot be opened by the SQLite builds that do not support it
+func WithWAL2() Option {
+	return func(c *Checkpointer) error {
+		c.enableWAL2 = true
+		return nil
+	}
+}
+
+// JournalMode returns "wal2" if every database is in WAL2 journal mode, "wal" otherwise
+func (c *Checkpointer) JournalMode() string {
+	if c.wal2 {
+		return "wal2"
+	}
+	return "wal"
+}
+
+// checkWAL returns an error if the schema of db (main if it is empty) is not in WAL or WAL2 journal mode,
+// unless enable is true and WAL can be set, wal2 is true if it is in WAL2 mode
+// With enableWAL2, it first tries to set WAL2
+func checkWAL(db queryer, schema string, enable, enableWAL2 bool) (wal2 bool, err error) {
+	ctx := context.Background()
+	pragma := schemaPragma(schema, "journal_mode")
+	var mode string
+	if err := db.QueryRowContext(ctx, pragma).Scan(&mode); err != nil {
+		return false, err
+	}
+	if strings.EqualFold(mode, "memory") {
+		return false, errInMemory
+	}
+	if enableWAL2 && !strings.EqualFold(mode, "wal2") {
+		// Builds without WAL2 ignore the unknown mode and return the current one
+		if err := db.QueryRowContext(ctx, pragma+` = wal2`).Scan(&mode); err != nil {
+			return false, err
+		}
+		enable = true
+	}
+	if strings.EqualFold(mode, "wal2") {
+		return true, nil
+	}
+	if strings.EqualFold(mode, "wal") {
+		return false, nil
+	}
+	if !enable {
+		return false, fmt.Errorf("sqlite: the database is in %q journal mode instead of WAL, set it in the DSN or use WithEnableWAL", mode)
+	}
+	if err := db.QueryRowContext(ctx, pragma+` = wal`).Scan(&mode); err != nil {
+		return false, err
+	}
+	if strings.EqualFold(mode, "memory") {
+		return false, errInMemory
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return false, fmt.Errorf("sqlite: could not set the WAL journal mode, the database is in %q journal mode", mode)
+	}
+	return false, nil
+}
+
+// WithManagedPragmas sets up the database for the Checkpointer: journal_mode = wal like WithEnableWAL,
+// synchronous = normal like WithSynchronous unless it is given, and wal_autocheckpoint = 0 (unless in WAL2 mode),
+// NewCheckpointer reads them back on a connection of the pool and returns an error combining the failures
+// They are settings of each connection, except journal_mode, see WithPragma of Open for the other connections
+func WithManagedPragmas() Option {
+	return func(c *Checkpointer) error {
+		c.enableWAL, c.managedPragmas = true, true
+		return nil
+	}
+}
+
+// checkManagedPragmas sets and reads back the pragmas of WithManagedPragmas on a connection of d,
+// except wal_autocheckpoint if it is set by the connector of d
+func (c *Checkpointer) checkManagedPragmas(d *database, wal2 bool) error {
+	ctx := context.Background()
+	conn, release, err := d.single(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	synchronous := c.synchronous
+	if synchronous == "" {
+		synchronous = "normal"
+	}
+	pragmas := []pragma{{"journal_mode", "wal"}, {"synchronous", synchronous}}
+	if wal2 {
+		pragmas[0].value = "wal2"
+	} else if !d.managed {
+		pragmas = append(pragmas, pragma{"wal_autocheckpoint", "0"})
+	}
+	var errs multiError
+	for _, p := range pragmas {
+		query := schemaPragma(c.schema, p.name)
+		if p.name != "journal_mode" {
+			if _, err := conn.ExecContext(ctx, query+` = `+p.value); err != nil {
+				errs = append(errs, fmt.Errorf("sqlite: setting pragma %s: %w", p.name, err))
+				continue
+			}
+		}
+		var got string
+		if err := conn.QueryRowContext(ctx, query).Scan(&got); err != nil {
+			errs = append(errs, fmt.Errorf("sqlite: checking pragma %s: %w", p.name, err))
+		} else if !strings.EqualFold(got, pragmaValue(p.name, p.value)) {
+			errs = append(errs, fmt.Errorf("sqlite: pragma %s is %q instead of %q", p.name, got, p.value))
+		}
+	}
+	return errs.err()
+}